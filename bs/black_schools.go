@@ -0,0 +1,735 @@
+// Package bs prices European options and computes their greeks and implied
+// volatility using the Black-Scholes model and its Merton (dividend yield) and
+// Black-76 (futures) variants.
+package bs
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// OptionType tells a call (CE) apart from a put (PE).
+type OptionType int
+
+const (
+	Ce OptionType = iota
+	Pe
+)
+
+func (self OptionType) String() string {
+	if self == Ce {
+		return "CE"
+	}
+	return "PE"
+}
+
+// Model selects the variant of Black-Scholes used for pricing. They differ in
+// the cost of carry of the underlying.
+type Model int
+
+const (
+	// Spot underlying without dividends, the cost of carry is the interest
+	// rate.
+	BlackScholes Model = iota
+	// Spot underlying paying a continuous dividend yield, the cost of carry is
+	// the interest rate less the yield.
+	Merton
+	// Futures underlying, the cost of carry is 0.
+	Black76
+)
+
+func (self Model) String() string {
+	switch self {
+	case Merton:
+		return "Merton"
+	case Black76:
+		return "Black-76"
+	default:
+		return "Black-Scholes"
+	}
+}
+
+var (
+	ErrInvalidStrike     = errors.New("Strike price cannot be 0")
+	ErrInvalidAssetPrice = errors.New("Asset price must be positive")
+	ErrNonPositivePrice  = errors.New("Option prices must be positive")
+	ErrIvNotConverged    = errors.New("IV calculation did not converge")
+)
+
+// OptionGreeks are the sensitivities of the option price. Theta, Charm and
+// Colour are per calendar day, Vega, Rho, Vanna, Volga and Zomma are per 1%
+// change in the volatility or the interest rate.
+type OptionGreeks struct {
+	Delta  float64
+	Delta2 float64
+	Theta  float64
+	Rho    float64
+	Vega   float64
+	Gamma  float64
+	IV     float64
+
+	// change in delta for a 1% rise in volatility
+	Vanna float64
+	// change in vega for a 1% rise in volatility, also called vomma
+	Volga float64
+	// change in delta over a day, also called delta decay
+	Charm float64
+	// change in gamma for a 1 point rise in the asset price
+	Speed float64
+	// change in gamma for a 1% rise in volatility
+	Zomma float64
+	// change in gamma over a day
+	Colour float64
+}
+
+// BlackSchools is the model of a strike. AssetPrice is the futures price for
+// Black-76 and the spot price otherwise. InterestRate, DividendYield,
+// DaysToExpiry and Volatility are stored as fractions of a year.
+type BlackSchools struct {
+	Model         Model
+	AssetPrice    float64
+	StrikePrice   float64
+	InterestRate  float64
+	DividendYield float64
+	DaysToExpiry  float64
+	Volatility    float64
+	CePrice       float64
+	PePrice       float64
+
+	CeGreeks      OptionGreeks
+	PeGreeks      OptionGreeks
+	PutCallParity float64
+}
+
+// NewBlackSchools creates the model of a strike. The interest rate and the
+// volatility are in percent and the time to expiry is in days. The CE and PE
+// prices are the market prices used to solve for the implied volatility, and
+// can be 0 when not needed.
+func NewBlackSchools(
+	assetPrice float64,
+	strikePrice float64,
+	interestRate float64,
+	daysToExpiry float64,
+	volatility float64,
+	cePrice float64,
+	pePrice float64) *BlackSchools {
+
+	return &BlackSchools{
+		Model:         BlackScholes,
+		AssetPrice:    assetPrice,
+		StrikePrice:   strikePrice,
+		InterestRate:  interestRate / 100,
+		DividendYield: 0,
+		DaysToExpiry:  daysToExpiry / 365,
+		Volatility:    volatility / 100,
+		CePrice:       cePrice,
+		PePrice:       pePrice,
+		CeGreeks: OptionGreeks{
+			Delta:  0,
+			Delta2: 0,
+			Theta:  0,
+			Rho:    0,
+			Vega:   0,
+			Gamma:  0,
+			IV:     0,
+			Vanna:  0,
+			Volga:  0,
+			Charm:  0,
+			Speed:  0,
+			Zomma:  0,
+			Colour: 0,
+		},
+		PeGreeks: OptionGreeks{
+			Delta:  0,
+			Delta2: 0,
+			Theta:  0,
+			Rho:    0,
+			Vega:   0,
+			Gamma:  0,
+			IV:     0,
+			Vanna:  0,
+			Volga:  0,
+			Charm:  0,
+			Speed:  0,
+			Zomma:  0,
+			Colour: 0,
+		},
+		PutCallParity: 0,
+	}
+}
+
+// NewMerton creates the model of a strike of a stock paying a continuous
+// dividend yield, in percent. The other arguments are the same as for
+// NewBlackSchools.
+func NewMerton(
+	assetPrice float64,
+	strikePrice float64,
+	interestRate float64,
+	dividendYield float64,
+	daysToExpiry float64,
+	volatility float64,
+	cePrice float64,
+	pePrice float64) *BlackSchools {
+
+	model := NewBlackSchools(assetPrice, strikePrice, interestRate,
+		daysToExpiry, volatility, cePrice, pePrice)
+	model.Model = Merton
+	model.DividendYield = dividendYield / 100
+	return model
+}
+
+// NewBlack76 creates the model of a strike priced off the futures expiring
+// with the option. The other arguments are the same as for NewBlackSchools,
+// the interest rate only discounts the payoff.
+func NewBlack76(
+	futuresPrice float64,
+	strikePrice float64,
+	interestRate float64,
+	daysToExpiry float64,
+	volatility float64,
+	cePrice float64,
+	pePrice float64) *BlackSchools {
+
+	model := NewBlackSchools(futuresPrice, strikePrice, interestRate,
+		daysToExpiry, volatility, cePrice, pePrice)
+	model.Model = Black76
+	return model
+}
+
+// CostOfCarry returns the annual cost of carrying the underlying, b in the
+// generalized Black-Scholes formula.
+func (self *BlackSchools) CostOfCarry() float64 {
+	switch self.Model {
+	case Merton:
+		return self.InterestRate - self.DividendYield
+	case Black76:
+		return 0
+	default:
+		return self.InterestRate
+	}
+}
+
+// carryFactor returns exp((b-r)T), the factor that turns the asset price into
+// the present value of the asset delivered on expiry.
+func (self *BlackSchools) carryFactor() float64 {
+	return math.Exp(
+		(self.CostOfCarry() - self.InterestRate) * self.DaysToExpiry)
+}
+
+// CalculateAValue calculates the value of 'a' used in the Black-Scholes formula.
+// 'a' is computed by multiplying the volatility of the option by the square
+// root of the number of days to expiry.
+// It returns the calculated value of 'a'.
+func (self *BlackSchools) CalculateAValue(volatility float64) float64 {
+	return volatility * math.Sqrt(self.DaysToExpiry)
+}
+
+// CalculateD1Value calculates the value of 'd1' in the Black-Scholes formula.
+// It involves several calculations based on the instance variables of the
+// BlackSchools struct. It returns the calculated value of 'd1'.
+func (self *BlackSchools) CalculateD1Value(volatility float64) float64 {
+	// (math.Log(self.AssetPrice/self.StrikePrice): It calculates the natural
+	// logarithm of the ratio of the asset price to the strike price.
+	// This term represents the logarithmic return of the asset.
+
+	// (self.CostOfCarry()+math.Pow(volatility, 2)/2)*self.DaysToExpiry):
+	// It calculates the sum of the cost of carry and half of the square of the
+	// volatility, multiplied by the number of days to expiry. This term
+	// represents the risk premium associated with the option.
+
+	// /self.CalculateAValue(): It divides the sum of the previous terms by the
+	// value calculated by the CalculateAValue() function. This value, denoted
+	// as 'a' in the Black-Scholes formula, represents the standard deviation
+	// of the asset's returns over the period.
+	return (math.Log(self.AssetPrice/self.StrikePrice) +
+		(self.CostOfCarry()+math.Pow(volatility, 2)/2)*self.DaysToExpiry) /
+		self.CalculateAValue(volatility)
+}
+
+// CalculateD2Value calculates the value of 'd2' in the Black-Scholes formula.
+// It involves several calculations based on the instance variables of the
+// BlackSchools struct. It returns the calculated value of 'd2'.
+func (self *BlackSchools) CalculateD2Value(volatility float64) float64 {
+	// The function calls two other methods: CalculateD1Value and
+	// CalculateAValue. It subtracts the value returned by CalculateAValue from
+	// the value returned by CalculateD1Value. This calculation represents the
+	// difference between the standard deviation of the asset's returns over the
+	// period (d1) and the standard deviation adjusted for the time to
+	// expiration (a).
+
+	// The resulting value of d2 is used in the Black-Scholes formula to
+	// calculate the probability that the option will be exercised. It plays a
+	// crucial role in determining the option's price and its sensitivity to
+	// changes in other variables like the underlying asset price, strike price,
+	// volatility, and time to expiration.
+	return self.CalculateD1Value(volatility) - self.CalculateAValue(volatility)
+}
+
+// Calculate the cumulative distribution function (CDF) of the standard normal
+// distribution at a given value. It returns the probability that a random
+// variable from a standard normal distribution is less than or equal to the
+// specified value.
+func (self *BlackSchools) NormCdf(x float64) float64 {
+	return distuv.Normal{Mu: 0, Sigma: 1}.CDF(x)
+}
+
+// NormPDF calculates the probability density function (PDF) of a standard
+// normal distribution at the given value x.
+// It uses the `distuv.UnitNormal` distribution from the
+// `gonum.org/v1/gonum/stat/distuv` package to compute the PDF.
+// The function returns the computed PDF value.
+func (self *BlackSchools) NormPDF(x float64) float64 {
+	// This function is used to calculate the probability density of the
+	// underlying asset's returns. It is commonly used to estimate the
+	// likelihood of different asset price scenarios and to calculate option
+	// Greeks like vega or rho, which represent the sensitivity of the option's
+	// price to changes in volatility or interest rates, respectively.
+	normalDist := distuv.UnitNormal
+	return normalDist.Prob(x)
+}
+
+func (self *BlackSchools) CalculateBValue() float64 {
+	// calculate the value of 'b', which is the exponential of the product of
+	// the interest rate and the number of days to expiration. This term
+	// represents the present value factor for discounting future cash flows.
+	return math.Exp(-self.InterestRate * self.DaysToExpiry)
+}
+
+func (self *BlackSchools) validate() error {
+	if self.StrikePrice <= 0 {
+		return ErrInvalidStrike
+	}
+	if self.AssetPrice <= 0 {
+		return ErrInvalidAssetPrice
+	}
+	return nil
+}
+
+// Price returns the price of the option for the given volatility.
+func (self *BlackSchools) Price(
+	optionType OptionType,
+	volatility float64) (float64, error) {
+
+	if err := self.validate(); err != nil {
+		return 0, err
+	}
+	if volatility <= 0 || self.DaysToExpiry <= 0 {
+		if optionType == Ce {
+			return maxFloat(0.0, self.AssetPrice-self.StrikePrice), nil
+		}
+		return maxFloat(0.0, self.StrikePrice-self.AssetPrice), nil
+	}
+	d1 := self.CalculateD1Value(volatility)
+	d2 := self.CalculateD2Value(volatility)
+	b := self.CalculateBValue()
+	asset := self.AssetPrice * self.carryFactor()
+	if optionType == Ce {
+		return asset*self.NormCdf(d1) - self.StrikePrice*b*self.NormCdf(d2), nil
+	}
+	return self.StrikePrice*b*self.NormCdf(-d2) - asset*self.NormCdf(-d1), nil
+}
+
+// Greeks returns the greeks of the call or the put at the model volatility.
+// The IV field is left 0, use ImpliedVolatility to solve for it.
+func (self *BlackSchools) Greeks(optionType OptionType) (OptionGreeks, error) {
+	return self.greeks(optionType, self.Volatility)
+}
+
+func (self *BlackSchools) greeks(
+	optionType OptionType,
+	volatility float64) (OptionGreeks, error) {
+
+	greeks := OptionGreeks{}
+	if err := self.validate(); err != nil {
+		return greeks, err
+	}
+
+	// On expiry, or without any volatility, the option is worth its intrinsic
+	// value. Only the delta of an in-the-money option is left.
+	if volatility <= 0 || self.DaysToExpiry <= 0 {
+		if optionType == Ce && self.AssetPrice > self.StrikePrice {
+			greeks.Delta = 1
+			greeks.Delta2 = -1
+		}
+		if optionType == Pe && self.AssetPrice < self.StrikePrice {
+			greeks.Delta = -1
+			greeks.Delta2 = 1
+		}
+		return greeks, nil
+	}
+
+	// b - represents the present value of the risk-free interest rate.
+	b := self.CalculateBValue()
+
+	// carry discounts the asset price for the dividends or, for futures, for
+	// the interest rate.
+	carry := self.carryFactor()
+	asset := self.AssetPrice * carry
+
+	// 'd1' and 'd2' are the inputs of the Black-Scholes formula used to
+	// calculate the cumulative distribution function (CDF) of the standard
+	// normal distribution. N(d2) is the probability of the call expiring
+	// in-the-money.
+	d1 := self.CalculateD1Value(volatility)
+	d2 := self.CalculateD2Value(volatility)
+	sqrtT := math.Sqrt(self.DaysToExpiry)
+
+	// The gamma value represents the sensitivity of the option's delta to
+	// small changes in the underlying asset price, and this sensitivity is the
+	// same for both put and call options at the same strike price.
+	greeks.Gamma = carry * self.NormPDF(d1) /
+		(self.AssetPrice * self.CalculateAValue(volatility))
+
+	// NormPDF(d1) The probability density function (PDF) of the standard normal
+	// distribution evaluated at d1. It measures the sensitivity of the
+	// option's value to changes in volatility.
+	// sqrt(DaysToExpiry): The square root of the number of days to expiry,
+	// used to adjust the vega calculation.
+	// / 100: A scaling factor to ensure the vega is expressed per 1% change
+	// in volatility.
+	greeks.Vega = asset * self.NormPDF(d1) * sqrtT / 100
+
+	// The second order greeks are the same for the call and the put except
+	// for the charm.
+	self.setSecondOrderGreeks(&greeks, volatility, d1, d2)
+
+	// -asset*self.NormPDF(d1)*volatility/(2*math.Sqrt(self.DaysToExpiry)):
+	// This term represents the contribution of the asset price, volatility, and
+	// time to expiry in the time decay calculation.
+	timeDecay := -asset * self.NormPDF(d1) * volatility / (2 * sqrtT)
+
+	// carryDecay is the decay of the asset price for the dividends or the
+	// futures discounting, (b-r) times the asset price.
+	carryDecay := (self.CostOfCarry() - self.InterestRate) * asset
+
+	switch optionType {
+	case Ce:
+		// Delta represents the sensitivity of the option's price to changes in
+		// the underlying asset price.
+		greeks.Delta = carry * self.NormCdf(d1)
+
+		// Delta2 represents the sensitivity of the option's price to changes
+		// in the strike price.
+		greeks.Delta2 = -self.NormCdf(d2) * b
+
+		// self.InterestRate*self.StrikePrice*b*self.NormCDF(d2): This term
+		// accounts for the risk-free interest rate, strike price, and
+		// probability of the option expiring in-the-money.
+		// To express Theta in terms of daily decay, we divide the computed
+		// value by 365.
+		greeks.Theta = (timeDecay - carryDecay*self.NormCdf(d1) -
+			self.InterestRate*self.StrikePrice*b*self.NormCdf(d2)) / 365
+
+		// Rho is the expected change in the option price for a 1% change in
+		// the risk-free interest rate, hence the division by 100.
+		greeks.Rho = self.StrikePrice * self.DaysToExpiry * b *
+			self.NormCdf(d2) / 100
+	default:
+		greeks.Delta = -carry * self.NormCdf(-d1)
+		greeks.Delta2 = self.NormCdf(-d2) * b
+		greeks.Theta = (timeDecay + carryDecay*self.NormCdf(-d1) +
+			self.InterestRate*self.StrikePrice*b*self.NormCdf(-d2)) / 365
+		greeks.Rho = -self.StrikePrice * self.DaysToExpiry * b *
+			self.NormCdf(-d2) / 100
+	}
+
+	// Charm is -d(delta)/dT, the change in delta as a day passes. The term
+	// shared with the put is the drift of N(d1), the carry term is the decay
+	// of the carry factor.
+	t := self.DaysToExpiry
+	drift := self.NormPDF(d1) *
+		(self.CostOfCarry()/(volatility*sqrtT) - d2/(2*t))
+	carryRate := self.CostOfCarry() - self.InterestRate
+	if optionType == Ce {
+		greeks.Charm = -carry * (drift + carryRate*self.NormCdf(d1)) / 365
+	} else {
+		greeks.Charm = -carry * (drift - carryRate*self.NormCdf(-d1)) / 365
+	}
+
+	// The futures price does not move with the interest rate, which only
+	// discounts the payoff.
+	if self.Model == Black76 {
+		price, _ := self.Price(optionType, volatility)
+		greeks.Rho = -self.DaysToExpiry * price / 100
+	}
+	return greeks, nil
+}
+
+// setSecondOrderGreeks sets vanna, volga, speed, zomma and colour from the
+// gamma and vega already set in greeks.
+func (self *BlackSchools) setSecondOrderGreeks(
+	greeks *OptionGreeks,
+	volatility float64,
+	d1 float64,
+	d2 float64) {
+
+	t := self.DaysToExpiry
+	sqrtT := math.Sqrt(t)
+	carry := self.carryFactor()
+
+	// d(delta)/d(sigma) = -exp((b-r)T) n(d1) d2 / sigma, scaled to 1% change.
+	greeks.Vanna = -carry * self.NormPDF(d1) * d2 / volatility / 100
+
+	// d(vega)/d(sigma) = vega d1 d2 / sigma. Vega is already per 1%.
+	greeks.Volga = greeks.Vega * d1 * d2 / volatility / 100
+
+	// d(gamma)/dS = -gamma (1 + d1 / (sigma sqrt(T))) / S.
+	greeks.Speed = -greeks.Gamma * (1 + d1/(volatility*sqrtT)) /
+		self.AssetPrice
+
+	// d(gamma)/d(sigma) = gamma (d1 d2 - 1) / sigma.
+	greeks.Zomma = greeks.Gamma * (d1*d2 - 1) / volatility / 100
+
+	// Colour is -d(gamma)/dT, the change in gamma as a day passes.
+	greeks.Colour = greeks.Gamma * (self.InterestRate - self.CostOfCarry() +
+		self.CostOfCarry()*d1/(volatility*sqrtT) +
+		(1-d1*d2)/(2*t)) / 365
+}
+
+// ComputeGreeks computes the greeks of the call and the put at the model
+// volatility and solves for the implied volatility using the CE price,
+// falling back to the PE price. The greeks are computed even when the implied
+// volatility cannot be solved, in which case the solver error is returned.
+func (self *BlackSchools) ComputeGreeks() error {
+	volatility := self.Volatility
+	if err := self.ComputeDelta(volatility); err != nil {
+		return err
+	}
+	self.ComputeDelta2(volatility)
+	self.ComputeVega(volatility)
+	self.ComputeTheta(volatility)
+	self.ComputeRho(volatility)
+	self.ComputeGamma(volatility)
+	self.ComputeSecondOrderGreeks(volatility)
+
+	err := self.ComputeIvUsingCePrice()
+	if err != nil {
+		err = self.ComputeIvUsingPePrice()
+	}
+	return err
+}
+
+func (self *BlackSchools) bothGreeks(
+	volatility float64) (OptionGreeks, OptionGreeks, error) {
+
+	ce, err := self.greeks(Ce, volatility)
+	if err != nil {
+		return ce, ce, err
+	}
+	pe, err := self.greeks(Pe, volatility)
+	return ce, pe, err
+}
+
+// The ComputeDelta method calculates the Delta value for the option using the
+// Black-Scholes formula. Delta represents the sensitivity of the option's
+// price to changes in the underlying asset price.
+// The method uses the NormCdf function to calculate the cumulative
+// distribution function (CDF) of the standard normal distribution for the
+// value of d1. The CDF represents the probability that a random variable from
+// a standard normal distribution is less than or equal to the given value.
+// The computed Delta value is assigned to the CeGreeks.Delta field for the
+// call option and to the PeGreeks.Delta field for the put option. The put
+// delta is negative.
+func (self *BlackSchools) ComputeDelta(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Delta = ce.Delta
+	self.PeGreeks.Delta = pe.Delta
+	return nil
+}
+
+// The ComputeDelta2 method is used to compute the second-order delta (Delta2)
+// for a given option contract using the Black-Scholes formula.
+func (self *BlackSchools) ComputeDelta2(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Delta2 = ce.Delta2
+	self.PeGreeks.Delta2 = pe.Delta2
+	return nil
+}
+
+// The ComputeVega() method calculates the Vega (sensitivity to volatility)
+// for both the call and put options based on the Black-Scholes model, taking
+// into account the underlying asset price, volatility, and days to expiry.
+func (self *BlackSchools) ComputeVega(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Vega = ce.Vega
+	self.PeGreeks.Vega = pe.Vega
+	return nil
+}
+
+// ComputeTheta computes the daily time decay of the call and the put.
+func (self *BlackSchools) ComputeTheta(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Theta = ce.Theta
+	self.PeGreeks.Theta = pe.Theta
+	return nil
+}
+
+// In the Black-Scholes model, Rho (ρ) represents the sensitivity of an
+// option's value to changes in the risk-free interest rate. It measures the
+// expected change in the option price for a 1% change in the risk-free
+// interest rate.
+func (self *BlackSchools) ComputeRho(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Rho = ce.Rho
+	self.PeGreeks.Rho = pe.Rho
+	return nil
+}
+
+// The function calculates the option gamma, which measures the rate of change
+// of the option's delta in relation to changes in the underlying asset price.
+func (self *BlackSchools) ComputeGamma(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Gamma = ce.Gamma
+	self.PeGreeks.Gamma = pe.Gamma
+	return nil
+}
+
+// ComputeSecondOrderGreeks computes vanna, volga, charm, speed, zomma and
+// colour of the call and the put. They tell how the delta and the vega hedges
+// drift with the asset price, the volatility and the time.
+func (self *BlackSchools) ComputeSecondOrderGreeks(volatility float64) error {
+	ce, pe, err := self.bothGreeks(volatility)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.Vanna = ce.Vanna
+	self.CeGreeks.Volga = ce.Volga
+	self.CeGreeks.Charm = ce.Charm
+	self.CeGreeks.Speed = ce.Speed
+	self.CeGreeks.Zomma = ce.Zomma
+	self.CeGreeks.Colour = ce.Colour
+	self.PeGreeks.Vanna = pe.Vanna
+	self.PeGreeks.Volga = pe.Volga
+	self.PeGreeks.Charm = pe.Charm
+	self.PeGreeks.Speed = pe.Speed
+	self.PeGreeks.Zomma = pe.Zomma
+	self.PeGreeks.Colour = pe.Colour
+	return nil
+}
+
+// Put-call parity is a principle in options pricing that establishes a
+// relationship between the prices of European-style call and put options with
+// the same strike price and expiration date. According to put-call parity,
+// the difference between the prices of a call option and a put option is
+// equal to the difference between the current price of the underlying asset
+// and the present value of the strike price.
+// Put-call parity is important because it helps ensure that there are no
+// arbitrage opportunities in the options market. It provides a relationship
+// between the prices of call and put options, allowing traders to compare the
+// prices and evaluate their relative value. If put-call parity is violated,
+// it could indicate mispricing in the options market, which could be
+// exploited by traders to make risk-free profits.
+// The put-call parity formula is as follows:
+//
+//	C - P = S - (K / (1 + r)^T)
+//
+// Where:
+// C is the price of the call option
+// P is the price of the put option
+// S is the current price of the underlying asset, discounted by
+// exp((b-r)T) for the dividends or, for Black-76, the futures price
+// K is the strike price
+// r is the risk-free interest rate
+// T is the time to expiration in years
+func (self *BlackSchools) ComputePutCallParity() {
+	self.PutCallParity = self.CePrice - self.PePrice -
+		self.AssetPrice*self.carryFactor() +
+		(self.StrikePrice / math.Pow(1+self.InterestRate, self.DaysToExpiry))
+}
+
+// ComputeOptionPrice sets CePrice and PePrice to the model prices for the
+// given volatility.
+func (self *BlackSchools) ComputeOptionPrice(volatility float64) error {
+	cePrice, err := self.Price(Ce, volatility)
+	if err != nil {
+		return err
+	}
+	pePrice, err := self.Price(Pe, volatility)
+	if err != nil {
+		return err
+	}
+	self.CePrice = cePrice
+	self.PePrice = pePrice
+	return nil
+}
+
+// ImpliedVolatility solves for the volatility at which the model price of the
+// call or the put matches the given market price using DefaultIvSolver. The
+// error is an *IvError telling why when the price cannot be matched.
+func (self *BlackSchools) ImpliedVolatility(
+	optionType OptionType,
+	price float64) (float64, error) {
+
+	return DefaultIvSolver().Solve(self, optionType, price)
+}
+
+// priceBounds returns the no-arbitrage bounds of the option price. A call is
+// worth at least the asset price less the discounted strike and at most the
+// asset price. A put is worth at least the discounted strike less the asset
+// price and at most the discounted strike. The asset price is discounted by
+// the carry of the model.
+func (self *BlackSchools) priceBounds(
+	optionType OptionType) (float64, float64) {
+
+	discountedStrike := self.StrikePrice * self.CalculateBValue()
+	asset := self.AssetPrice * self.carryFactor()
+	if optionType == Ce {
+		return maxFloat(0.0, asset-discountedStrike), asset
+	}
+	return maxFloat(0.0, discountedStrike-asset), discountedStrike
+}
+
+// Calculate the implied volatility (IV) for the given option prices
+// (CePrice and PePrice) using the Black-Scholes model. The IV represents the
+// market's expectation of the future volatility of the underlying asset based
+// on the observed option prices.
+// The ComputeIvUsingPePrice() function solves using the PE price and sets the
+// IV of both the call and the put. Only the PE price needs to be positive.
+func (self *BlackSchools) ComputeIvUsingPePrice() error {
+	iv, err := self.ImpliedVolatility(Pe, self.PePrice)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.IV = iv
+	self.PeGreeks.IV = iv
+	return nil
+}
+
+// The ComputeIvUsingCePrice() function solves using the CE price and sets the
+// IV of both the call and the put. Only the CE price needs to be positive.
+func (self *BlackSchools) ComputeIvUsingCePrice() error {
+	iv, err := self.ImpliedVolatility(Ce, self.CePrice)
+	if err != nil {
+		return err
+	}
+	self.CeGreeks.IV = iv
+	self.PeGreeks.IV = iv
+	return nil
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
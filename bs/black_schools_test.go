@@ -0,0 +1,138 @@
+package bs
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	kTestAssetPrice = 100.0
+	kTestStrike     = 105.0
+	kTestRate       = 7.0
+	kTestYield      = 3.0
+	kTestDays       = 30.0
+	kTestVolatility = 25.0
+)
+
+func assertClose(t *testing.T, name string, got float64, want float64,
+	tolerance float64) {
+
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("%s: got %.6f, want %.6f within %g", name, got, want,
+			tolerance)
+	}
+}
+
+// Hull, Options, Futures and Other Derivatives, example 15.6: S=42, K=40,
+// r=10%, sigma=20%, T=0.5.
+func TestPriceReferenceValues(t *testing.T) {
+	model := NewBlackSchools(42, 40, 10, 0.5*365, 20, 0, 0)
+	ce, err := model.Price(Ce, model.Volatility)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pe, err := model.Price(Pe, model.Volatility)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "CE price", ce, 4.7594, 1e-4)
+	assertClose(t, "PE price", pe, 0.8086, 1e-4)
+}
+
+// Hull, Options, Futures and Other Derivatives, the call of chapter 19: S=49,
+// K=50, r=5%, sigma=20%, T=20 weeks. Vega and rho are per 1% and theta is per
+// calendar day.
+func TestGreeksReferenceValues(t *testing.T) {
+	model := NewBlackSchools(49, 50, 5, 20.0/52*365, 20, 0, 0)
+	greeks, err := model.Greeks(Ce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "Delta", greeks.Delta, 0.522, 1e-3)
+	assertClose(t, "Gamma", greeks.Gamma, 0.066, 1e-3)
+	assertClose(t, "Vega", greeks.Vega, 0.121, 1e-3)
+	assertClose(t, "Theta", greeks.Theta, -4.31/365, 1e-4)
+	assertClose(t, "Rho", greeks.Rho, 0.0891, 1e-4)
+}
+
+func newTestModel(
+	model Model,
+	assetPrice float64,
+	days float64,
+	volatility float64) *BlackSchools {
+
+	switch model {
+	case Merton:
+		return NewMerton(assetPrice, kTestStrike, kTestRate, kTestYield, days,
+			volatility, 0, 0)
+	case Black76:
+		return NewBlack76(assetPrice, kTestStrike, kTestRate, days,
+			volatility, 0, 0)
+	default:
+		return NewBlackSchools(assetPrice, kTestStrike, kTestRate, days,
+			volatility, 0, 0)
+	}
+}
+
+func testGreeks(
+	t *testing.T,
+	model Model,
+	optionType OptionType,
+	assetPrice float64,
+	days float64,
+	volatility float64) OptionGreeks {
+
+	t.Helper()
+	greeks, err := newTestModel(model, assetPrice, days, volatility).
+		Greeks(optionType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return greeks
+}
+
+// The second order greeks are checked against central differences of the
+// first order greeks, in the units of the greeks: per 1% of volatility, per
+// point of the asset price and per calendar day.
+func TestSecondOrderGreeksMatchFiniteDifferences(t *testing.T) {
+	const dVol = 0.01
+	const dAsset = 0.01
+	const dDays = 0.01
+
+	for _, model := range []Model{BlackScholes, Merton, Black76} {
+		for _, optionType := range []OptionType{Ce, Pe} {
+			name := model.String() + " " + optionType.String()
+			greeks := testGreeks(t, model, optionType, kTestAssetPrice,
+				kTestDays, kTestVolatility)
+
+			volUp := testGreeks(t, model, optionType, kTestAssetPrice,
+				kTestDays, kTestVolatility+dVol)
+			volDown := testGreeks(t, model, optionType, kTestAssetPrice,
+				kTestDays, kTestVolatility-dVol)
+			assertClose(t, name+" Vanna", greeks.Vanna,
+				(volUp.Delta-volDown.Delta)/(2*dVol), 1e-6)
+			assertClose(t, name+" Volga", greeks.Volga,
+				(volUp.Vega-volDown.Vega)/(2*dVol), 1e-6)
+			assertClose(t, name+" Zomma", greeks.Zomma,
+				(volUp.Gamma-volDown.Gamma)/(2*dVol), 1e-6)
+
+			assetUp := testGreeks(t, model, optionType,
+				kTestAssetPrice+dAsset, kTestDays, kTestVolatility)
+			assetDown := testGreeks(t, model, optionType,
+				kTestAssetPrice-dAsset, kTestDays, kTestVolatility)
+			assertClose(t, name+" Speed", greeks.Speed,
+				(assetUp.Gamma-assetDown.Gamma)/(2*dAsset), 1e-6)
+
+			// a day passing is the time to expiry going down
+			later := testGreeks(t, model, optionType, kTestAssetPrice,
+				kTestDays-dDays, kTestVolatility)
+			earlier := testGreeks(t, model, optionType, kTestAssetPrice,
+				kTestDays+dDays, kTestVolatility)
+			assertClose(t, name+" Charm", greeks.Charm,
+				(later.Delta-earlier.Delta)/(2*dDays), 1e-6)
+			assertClose(t, name+" Colour", greeks.Colour,
+				(later.Gamma-earlier.Gamma)/(2*dDays), 1e-6)
+		}
+	}
+}
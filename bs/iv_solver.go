@@ -0,0 +1,258 @@
+package bs
+
+import (
+	"fmt"
+	"math"
+)
+
+// IvFailureReason tells why the implied volatility could not be solved.
+type IvFailureReason int
+
+const (
+	// The market price is 0 or negative.
+	IvNonPositivePrice IvFailureReason = iota
+	// The option has expired, so its price does not depend on volatility.
+	IvExpired
+	// The market price is below the no-arbitrage lower bound, the discounted
+	// intrinsic value.
+	IvBelowIntrinsic
+	// The market price is at or above the no-arbitrage upper bound, the
+	// asset price for a call and the discounted strike for a put.
+	IvAboveUpperBound
+	// The market price is outside the prices at the solver volatility bounds.
+	IvNotBracketed
+	// The solver ran out of iterations.
+	IvNotConverged
+)
+
+func (self IvFailureReason) String() string {
+	switch self {
+	case IvNonPositivePrice:
+		return "price is not positive"
+	case IvExpired:
+		return "option has expired"
+	case IvBelowIntrinsic:
+		return "price is below the intrinsic value"
+	case IvAboveUpperBound:
+		return "price is above the no-arbitrage upper bound"
+	case IvNotBracketed:
+		return "price is outside the volatility bounds"
+	default:
+		return "solver did not converge"
+	}
+}
+
+// IvError is returned when the implied volatility cannot be solved. It
+// matches ErrNonPositivePrice and ErrIvNotConverged with errors.Is for the
+// corresponding reasons.
+type IvError struct {
+	Reason     IvFailureReason
+	OptionType OptionType
+	Price      float64
+	// The range of prices the solver could match. For IvBelowIntrinsic and
+	// IvAboveUpperBound they are the no-arbitrage bounds, for IvNotBracketed
+	// the prices at the volatility bounds.
+	LowerPrice float64
+	UpperPrice float64
+}
+
+func (self *IvError) Error() string {
+	return fmt.Sprintf("IV of %s price=%.4f cannot be solved, %s "+
+		"(price range %.4f to %.4f)", self.OptionType, self.Price, self.Reason,
+		self.LowerPrice, self.UpperPrice)
+}
+
+func (self *IvError) Is(target error) bool {
+	switch target {
+	case ErrNonPositivePrice:
+		return self.Reason == IvNonPositivePrice
+	case ErrIvNotConverged:
+		return self.Reason == IvNotConverged
+	}
+	return false
+}
+
+// IvSolver solves for the implied volatility using Newton-Raphson and falls
+// back to Brent's method when the vega is too small for a Newton step or the
+// step leaves the bounds. Volatilities are fractions, 1.0 is 100%.
+type IvSolver struct {
+	LowerBound     float64
+	UpperBound     float64
+	PriceTolerance float64
+	MaxIterations  int
+	// Newton steps are not taken when the vega, per unit of volatility, is
+	// below MinVega.
+	MinVega float64
+}
+
+// DefaultIvSolver searches volatilities between 0.01% and 500%, which covers
+// deep OTM weekly options.
+func DefaultIvSolver() IvSolver {
+	return IvSolver{
+		LowerBound:     0.0001,
+		UpperBound:     5.0,
+		PriceTolerance: 1e-6,
+		MaxIterations:  100,
+		MinVega:        1e-8,
+	}
+}
+
+// Solve returns the volatility at which the model price of the call or the
+// put matches the market price.
+func (self IvSolver) Solve(
+	model *BlackSchools,
+	optionType OptionType,
+	price float64) (float64, error) {
+
+	if err := model.validate(); err != nil {
+		return 0, err
+	}
+	fail := func(reason IvFailureReason, lower float64, upper float64) error {
+		return &IvError{
+			Reason:     reason,
+			OptionType: optionType,
+			Price:      price,
+			LowerPrice: lower,
+			UpperPrice: upper,
+		}
+	}
+
+	if price <= 0 {
+		return 0, fail(IvNonPositivePrice, 0, 0)
+	}
+	if model.DaysToExpiry <= 0 {
+		return 0, fail(IvExpired, 0, 0)
+	}
+
+	// No volatility can produce a price outside the no-arbitrage bounds.
+	lower, upper := model.priceBounds(optionType)
+	if price < lower-self.PriceTolerance {
+		return 0, fail(IvBelowIntrinsic, lower, upper)
+	}
+	if price >= upper {
+		return 0, fail(IvAboveUpperBound, lower, upper)
+	}
+
+	// objective is the model price minus the market price. It increases with
+	// the volatility.
+	objective := func(volatility float64) float64 {
+		modelPrice, _ := model.Price(optionType, volatility)
+		return modelPrice - price
+	}
+	fLow := objective(self.LowerBound)
+	fHigh := objective(self.UpperBound)
+	if math.Abs(fLow) <= self.PriceTolerance {
+		return self.LowerBound, nil
+	}
+	if math.Abs(fHigh) <= self.PriceTolerance {
+		return self.UpperBound, nil
+	}
+	if fLow > 0 || fHigh < 0 {
+		return 0, fail(IvNotBracketed, fLow+price, fHigh+price)
+	}
+
+	if iv, ok := self.newton(model, optionType, price, objective); ok {
+		return iv, nil
+	}
+	if iv, ok := self.brent(objective, fLow, fHigh); ok {
+		return iv, nil
+	}
+	return 0, fail(IvNotConverged, fLow+price, fHigh+price)
+}
+
+func (self IvSolver) newton(
+	model *BlackSchools,
+	optionType OptionType,
+	price float64,
+	objective func(float64) float64) (float64, bool) {
+
+	// Brenner-Subrahmanyam approximation of the ATM volatility as the first
+	// guess.
+	sqrtT := math.Sqrt(model.DaysToExpiry)
+	iv := math.Sqrt(2*math.Pi) / sqrtT * price / model.AssetPrice
+	iv = math.Min(math.Max(iv, self.LowerBound), self.UpperBound)
+
+	for ii := 0; ii < self.MaxIterations; ii += 1 {
+		f := objective(iv)
+		if math.Abs(f) <= self.PriceTolerance {
+			return iv, true
+		}
+		greeks, err := model.greeks(optionType, iv)
+		if err != nil {
+			return 0, false
+		}
+		// Vega is per 1% change in volatility.
+		vega := greeks.Vega * 100
+		if vega < self.MinVega {
+			return 0, false
+		}
+		next := iv - f/vega
+		if next <= self.LowerBound || next >= self.UpperBound {
+			return 0, false
+		}
+		iv = next
+	}
+	return 0, false
+}
+
+// brent finds the root of the objective in [LowerBound, UpperBound] using
+// Brent's method. fLow and fHigh are the objective at the bounds and must
+// have opposite signs.
+func (self IvSolver) brent(
+	objective func(float64) float64,
+	fLow float64,
+	fHigh float64) (float64, bool) {
+
+	a, b := self.LowerBound, self.UpperBound
+	fa, fb := fLow, fHigh
+	if math.Abs(fa) < math.Abs(fb) {
+		a, b = b, a
+		fa, fb = fb, fa
+	}
+	c, fc := a, fa
+	d := 0.0
+	bisected := true
+
+	for ii := 0; ii < self.MaxIterations; ii += 1 {
+		if math.Abs(fb) <= self.PriceTolerance {
+			return b, true
+		}
+
+		var s float64
+		if fa != fc && fb != fc {
+			// inverse quadratic interpolation
+			s = a*fb*fc/((fa-fb)*(fa-fc)) +
+				b*fa*fc/((fb-fa)*(fb-fc)) +
+				c*fa*fb/((fc-fa)*(fc-fb))
+		} else {
+			// secant
+			s = b - fb*(b-a)/(fb-fa)
+		}
+
+		tolerance := 1e-12
+		between := (s > (3*a+b)/4 && s < b) || (s < (3*a+b)/4 && s > b)
+		if !between ||
+			(bisected && math.Abs(s-b) >= math.Abs(b-c)/2) ||
+			(!bisected && math.Abs(s-b) >= math.Abs(c-d)/2) ||
+			(bisected && math.Abs(b-c) < tolerance) ||
+			(!bisected && math.Abs(c-d) < tolerance) {
+			s = (a + b) / 2
+			bisected = true
+		} else {
+			bisected = false
+		}
+
+		fs := objective(s)
+		d, c, fc = c, b, fb
+		if fa*fs < 0 {
+			b, fb = s, fs
+		} else {
+			a, fa = s, fs
+		}
+		if math.Abs(fa) < math.Abs(fb) {
+			a, b = b, a
+			fa, fb = fb, fa
+		}
+	}
+	return 0, false
+}
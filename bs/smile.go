@@ -0,0 +1,309 @@
+package bs
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/interp"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Delta25 is the delta of the strikes quoted by the 25-delta risk reversal
+// and butterfly.
+const Delta25 = 0.25
+
+var (
+	ErrInvalidForward    = errors.New("Forward price must be positive")
+	ErrInvalidExpiry     = errors.New("Time to expiry must be positive")
+	ErrTooFewSmilePoints = errors.New("Smile needs the IV of at least 2 strikes")
+	ErrEmptySurface      = errors.New("Surface needs at least 1 smile")
+)
+
+// SmilePoint is the implied volatility, a fraction, of a strike.
+type SmilePoint struct {
+	Strike float64
+	IV     float64
+}
+
+// Smile is the implied volatility across the strikes of an expiry. The IVs
+// are fitted with a natural cubic spline over the log-moneyness ln(K/F).
+// Outside the fitted strikes the IV of the nearest fitted strike is used.
+type Smile struct {
+	forward      float64
+	daysToExpiry float64
+	// points in ascending order of strike
+	points []SmilePoint
+	spline interp.NaturalCubic
+}
+
+// NewSmile fits the smile of an expiry daysToExpiry days away whose forward
+// (futures) price is forward. Points without a positive IV are dropped and,
+// of the points with the same strike, the first one is kept.
+func NewSmile(
+	forward float64,
+	daysToExpiry float64,
+	points []SmilePoint) (*Smile, error) {
+
+	if forward <= 0 {
+		return nil, ErrInvalidForward
+	}
+	if daysToExpiry <= 0 {
+		return nil, ErrInvalidExpiry
+	}
+
+	sorted := make([]SmilePoint, 0, len(points))
+	for _, point := range points {
+		if point.Strike > 0 && point.IV > 0 {
+			sorted = append(sorted, point)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Strike < sorted[j].Strike
+	})
+	unique := sorted[:0]
+	for _, point := range sorted {
+		if len(unique) > 0 && unique[len(unique)-1].Strike == point.Strike {
+			continue
+		}
+		unique = append(unique, point)
+	}
+	if len(unique) < 2 {
+		return nil, ErrTooFewSmilePoints
+	}
+
+	xs := make([]float64, len(unique))
+	ys := make([]float64, len(unique))
+	for ii, point := range unique {
+		xs[ii] = math.Log(point.Strike / forward)
+		ys[ii] = point.IV
+	}
+	smile := &Smile{
+		forward:      forward,
+		daysToExpiry: daysToExpiry,
+		points:       unique,
+		spline:       interp.NaturalCubic{},
+	}
+	if err := smile.spline.Fit(xs, ys); err != nil {
+		return nil, err
+	}
+	return smile, nil
+}
+
+func (self *Smile) Forward() float64 {
+	return self.forward
+}
+
+func (self *Smile) DaysToExpiry() float64 {
+	return self.daysToExpiry
+}
+
+// Points returns the fitted points in ascending order of strike.
+func (self *Smile) Points() []SmilePoint {
+	points := make([]SmilePoint, len(self.points))
+	copy(points, self.points)
+	return points
+}
+
+// IV returns the implied volatility of the strike.
+func (self *Smile) IV(strike float64) float64 {
+	if strike <= 0 {
+		return self.points[0].IV
+	}
+	return self.ivAtMoneyness(math.Log(strike / self.forward))
+}
+
+func (self *Smile) ivAtMoneyness(moneyness float64) float64 {
+	iv := self.spline.Predict(moneyness)
+	// The spline can undershoot between widely spaced points.
+	if iv <= 0 {
+		iv = self.nearestPoint(moneyness).IV
+	}
+	return iv
+}
+
+func (self *Smile) nearestPoint(moneyness float64) SmilePoint {
+	strike := self.forward * math.Exp(moneyness)
+	nearest := self.points[0]
+	for _, point := range self.points {
+		if math.Abs(point.Strike-strike) < math.Abs(nearest.Strike-strike) {
+			nearest = point
+		}
+	}
+	return nearest
+}
+
+// AtmIV returns the implied volatility at the forward price.
+func (self *Smile) AtmIV() float64 {
+	return self.ivAtMoneyness(0)
+}
+
+// StrikeForDelta returns the strike whose forward delta, N(d1) for a call and
+// N(d1) - 1 for a put, is delta using the IV of the smile at that strike. The
+// delta of a put can be given with either sign.
+func (self *Smile) StrikeForDelta(
+	optionType OptionType,
+	delta float64) float64 {
+
+	// The call delta is 1 - |put delta| at the same strike.
+	callDelta := math.Abs(delta)
+	if optionType == Pe {
+		callDelta = 1 - callDelta
+	}
+	years := self.daysToExpiry / 365
+	sqrtT := math.Sqrt(years)
+	forwardDelta := func(moneyness float64) float64 {
+		iv := self.ivAtMoneyness(moneyness)
+		d1 := (-moneyness + iv*iv*years/2) / (iv * sqrtT)
+		return distuv.UnitNormal.CDF(d1)
+	}
+
+	// The call delta falls as the strike rises. Widen the bracket until it
+	// holds the delta, then bisect.
+	width := 5 * self.AtmIV() * sqrtT
+	low, high := -width, width
+	for ii := 0; ii < 10 && forwardDelta(low) < callDelta; ii += 1 {
+		low *= 2
+	}
+	for ii := 0; ii < 10 && forwardDelta(high) > callDelta; ii += 1 {
+		high *= 2
+	}
+	for ii := 0; ii < 100; ii += 1 {
+		mid := (low + high) / 2
+		if forwardDelta(mid) > callDelta {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return self.forward * math.Exp((low+high)/2)
+}
+
+// ProbabilityAbove returns the risk-neutral probability, N(d2), of the
+// underlying expiring above the strike, using the IV of the smile at the
+// strike.
+func (self *Smile) ProbabilityAbove(strike float64) float64 {
+	if strike <= 0 {
+		return 1
+	}
+	iv := self.IV(strike)
+	years := self.daysToExpiry / 365
+	d2 := (math.Log(self.forward/strike) - iv*iv*years/2) /
+		(iv * math.Sqrt(years))
+	return distuv.UnitNormal.CDF(d2)
+}
+
+// ProbabilityBelow returns the risk-neutral probability of the underlying
+// expiring below the strike.
+func (self *Smile) ProbabilityBelow(strike float64) float64 {
+	return 1 - self.ProbabilityAbove(strike)
+}
+
+// RiskReversal returns the IV of the call less the IV of the put at the
+// given delta. A negative value means puts are bid over calls.
+func (self *Smile) RiskReversal(delta float64) float64 {
+	ceIv := self.IV(self.StrikeForDelta(Ce, delta))
+	peIv := self.IV(self.StrikeForDelta(Pe, delta))
+	return ceIv - peIv
+}
+
+// Butterfly returns the average IV of the call and the put at the given delta
+// less the ATM IV, a measure of the curvature of the smile.
+func (self *Smile) Butterfly(delta float64) float64 {
+	ceIv := self.IV(self.StrikeForDelta(Ce, delta))
+	peIv := self.IV(self.StrikeForDelta(Pe, delta))
+	return (ceIv+peIv)/2 - self.AtmIV()
+}
+
+// Surface is the implied volatility across strikes and expiries. Between two
+// expiries the total variance, IV^2 * T, is interpolated linearly in time at
+// the same log-moneyness. Before the first expiry and after the last one the
+// smile of that expiry is used.
+type Surface struct {
+	// smiles in ascending order of expiry
+	smiles []*Smile
+}
+
+// NewSurface builds the surface from the smiles of each expiry. Of the smiles
+// with the same time to expiry, the first one is kept.
+func NewSurface(smiles []*Smile) (*Surface, error) {
+	sorted := make([]*Smile, 0, len(smiles))
+	for _, smile := range smiles {
+		if smile != nil {
+			sorted = append(sorted, smile)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].daysToExpiry < sorted[j].daysToExpiry
+	})
+	unique := sorted[:0]
+	for _, smile := range sorted {
+		if len(unique) > 0 &&
+			unique[len(unique)-1].daysToExpiry == smile.daysToExpiry {
+			continue
+		}
+		unique = append(unique, smile)
+	}
+	if len(unique) == 0 {
+		return nil, ErrEmptySurface
+	}
+	return &Surface{smiles: unique}, nil
+}
+
+// Smiles returns the smiles in ascending order of expiry.
+func (self *Surface) Smiles() []*Smile {
+	smiles := make([]*Smile, len(self.smiles))
+	copy(smiles, self.smiles)
+	return smiles
+}
+
+// bracket returns the smiles around the time to expiry and the weight of the
+// later one. Both smiles are the same outside the expiries of the surface.
+func (self *Surface) bracket(daysToExpiry float64) (*Smile, *Smile, float64) {
+	first := self.smiles[0]
+	last := self.smiles[len(self.smiles)-1]
+	if daysToExpiry <= first.daysToExpiry {
+		return first, first, 0
+	}
+	if daysToExpiry >= last.daysToExpiry {
+		return last, last, 0
+	}
+	ii := sort.Search(len(self.smiles), func(i int) bool {
+		return self.smiles[i].daysToExpiry > daysToExpiry
+	})
+	before, after := self.smiles[ii-1], self.smiles[ii]
+	weight := (daysToExpiry - before.daysToExpiry) /
+		(after.daysToExpiry - before.daysToExpiry)
+	return before, after, weight
+}
+
+// Forward returns the forward price for the time to expiry, interpolating the
+// log of the forward prices linearly in time.
+func (self *Surface) Forward(daysToExpiry float64) float64 {
+	before, after, weight := self.bracket(daysToExpiry)
+	return math.Exp((1-weight)*math.Log(before.forward) +
+		weight*math.Log(after.forward))
+}
+
+// IV returns the implied volatility of the strike for the time to expiry in
+// days.
+func (self *Surface) IV(strike float64, daysToExpiry float64) float64 {
+	before, after, weight := self.bracket(daysToExpiry)
+	if strike <= 0 || weight == 0 {
+		return before.IV(strike)
+	}
+
+	moneyness := math.Log(strike / self.Forward(daysToExpiry))
+	ivBefore := before.ivAtMoneyness(moneyness)
+	ivAfter := after.ivAtMoneyness(moneyness)
+	varianceBefore := ivBefore * ivBefore * before.daysToExpiry
+	varianceAfter := ivAfter * ivAfter * after.daysToExpiry
+	variance := (1-weight)*varianceBefore + weight*varianceAfter
+	return math.Sqrt(variance / daysToExpiry)
+}
+
+// AtmIV returns the implied volatility at the forward price for the time to
+// expiry in days.
+func (self *Surface) AtmIV(daysToExpiry float64) float64 {
+	return self.IV(self.Forward(daysToExpiry), daysToExpiry)
+}
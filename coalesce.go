@@ -0,0 +1,111 @@
+package nse
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const kDefaultCoalesceWindow = time.Second
+
+// ocCall is a fetch of an option chain URL shared by the callers asking for
+// the URL while it is in flight or within the window after it completed.
+type ocCall struct {
+	done     chan struct{}
+	resp     *NseOcResponse
+	err      error
+	finished time.Time
+}
+
+// ocFlightGroup coalesces the concurrent fetches of the same option chain
+// URL into one HTTP round trip, in the spirit of singleflight. A successful
+// response is also shared with the callers arriving within the window after
+// it completed. The shared response must be treated as read only.
+type ocFlightGroup struct {
+	mutex  sync.Mutex
+	window time.Duration
+	calls  map[string]*ocCall
+}
+
+func newOcFlightGroup(window time.Duration) *ocFlightGroup {
+	return &ocFlightGroup{
+		mutex:  sync.Mutex{},
+		window: window,
+		calls:  map[string]*ocCall{},
+	}
+}
+
+func (self *ocFlightGroup) setWindow(window time.Duration) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.window = window
+}
+
+// do returns the response of the in flight or recent fetch of the URL, or
+// fetches it. A caller whose context is still live retries when the shared
+// fetch failed because the context of the caller that made it was done.
+func (self *ocFlightGroup) do(
+	ctx context.Context,
+	url string,
+	fetch func(ctx context.Context) (*NseOcResponse, error)) (
+	*NseOcResponse, error) {
+
+	for {
+		self.mutex.Lock()
+		call, ok := self.calls[url]
+		if ok && !call.finished.IsZero() &&
+			time.Since(call.finished) > self.window {
+			delete(self.calls, url)
+			ok = false
+		}
+		if !ok {
+			call = &ocCall{
+				done:     make(chan struct{}),
+				resp:     nil,
+				err:      nil,
+				finished: time.Time{},
+			}
+			self.calls[url] = call
+			self.mutex.Unlock()
+			self.fetch(ctx, url, call, fetch)
+			return call.resp, call.err
+		}
+		self.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		if call.err != nil && isContextError(call.err) && ctx.Err() == nil {
+			continue
+		}
+		return call.resp, call.err
+	}
+}
+
+func (self *ocFlightGroup) fetch(
+	ctx context.Context,
+	url string,
+	call *ocCall,
+	fetch func(ctx context.Context) (*NseOcResponse, error)) {
+
+	call.resp, call.err = fetch(ctx)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	call.finished = time.Now()
+	// failures are not shared with the callers arriving later
+	if call.err != nil || self.window <= 0 {
+		if self.calls[url] == call {
+			delete(self.calls, url)
+		}
+	}
+	close(call.done)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
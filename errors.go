@@ -0,0 +1,54 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const kErrorBodySnippetLength = 256
+
+var (
+	// NSE rejected the session cookie, status 401.
+	ErrUnauthorized = errors.New("Unauthorized by NSE")
+	// NSE blocked the client, status 403. Retrying right away prolongs the
+	// block, so back off for minutes.
+	ErrForbidden = errors.New("Forbidden by NSE")
+	// NSE throttled the client, status 429.
+	ErrRateLimited = errors.New("Rate limited by NSE")
+	// The response of NSE could not be parsed.
+	ErrParse = errors.New("Invalid NSE response")
+)
+
+// HTTPStatusError is returned when NSE answers with a status other than 200
+// after the retries allowed by the retry policy. It matches ErrUnauthorized,
+// ErrForbidden and ErrRateLimited with errors.Is for their statuses.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+	// the start of the response body
+	Body string
+}
+
+func (self *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Fetching URL=%s failed with status=%d body=%q",
+		self.URL, self.StatusCode, self.Body)
+}
+
+func (self *HTTPStatusError) Unwrap() error {
+	switch self.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// newParseError returns an error with the message which matches ErrParse.
+func newParseError(msg string) error {
+	return fmt.Errorf("%w. %s", ErrParse, msg)
+}
@@ -55,7 +55,7 @@ func (NseFOData) Parse(buffer *bytes.Buffer) ([]NseFODataRecord, error) {
 	lines := bytes.Split(buffer.Bytes(), []byte{'\n'})
 
 	// Check if first line starts with a double quote and remove it if necessary
-	if len(lines) > 0 && lines[0][0] == '"' {
+	if len(lines) > 0 && len(lines[0]) > 0 && lines[0][0] == '"' {
 		lines = lines[1:]
 	}
 
@@ -67,7 +67,10 @@ func (NseFOData) Parse(buffer *bytes.Buffer) ([]NseFODataRecord, error) {
 	// Read the header row
 	header, err := reader.Read()
 	if err != nil {
-		return nil, err
+		msg := fmt.Sprintf("Reading the F&O CSV header failed with error=%s",
+			err)
+		glog.Error(msg)
+		return nil, newParseError(msg)
 	}
 	glog.Info("Header ", header)
 
@@ -86,7 +89,9 @@ func (NseFOData) Parse(buffer *bytes.Buffer) ([]NseFODataRecord, error) {
 			break
 		}
 		if err != nil {
-			return nil, err
+			msg := fmt.Sprintf("Reading the F&O CSV failed with error=%s", err)
+			glog.Error(msg)
+			return nil, newParseError(msg)
 		}
 
 		record := NseFODataRecord{}
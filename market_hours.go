@@ -0,0 +1,114 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	kHolidayKeyLayout = "2006-01-02"
+	// bound on the days NextOpen looks ahead, in case every day is a holiday
+	kMaxClosedDays = 366
+)
+
+// MarketCalendar knows when the NSE F&O segment trades: 09:15 to 15:30 IST,
+// Monday to Friday, except on the exchange holidays added to the calendar.
+// NSE publishes the holidays of a year in advance, so the calendar starts
+// empty and the caller adds them. A MarketCalendar is safe for concurrent use.
+type MarketCalendar struct {
+	mutex    sync.RWMutex
+	holidays map[string]bool
+}
+
+func NewMarketCalendar(holidays ...time.Time) *MarketCalendar {
+	self := &MarketCalendar{
+		mutex:    sync.RWMutex{},
+		holidays: map[string]bool{},
+	}
+	for _, holiday := range holidays {
+		self.AddHoliday(holiday)
+	}
+	return self
+}
+
+// AddHoliday marks the day of the given time in IST as an exchange holiday.
+func (self *MarketCalendar) AddHoliday(date time.Time) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.holidays[date.In(IST).Format(kHolidayKeyLayout)] = true
+}
+
+// AddHolidayDate marks a date like "26-Jan-2023" as an exchange holiday.
+func (self *MarketCalendar) AddHolidayDate(date string) error {
+	holiday, err := time.ParseInLocation(kOcExpiryDateLayout, date, IST)
+	if err != nil {
+		msg := fmt.Sprintf("Parsing holiday=%s failed with error=%s", date, err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	self.AddHoliday(holiday)
+	return nil
+}
+
+// IsHoliday returns true if the day of the given time in IST is an exchange
+// holiday.
+func (self *MarketCalendar) IsHoliday(t time.Time) bool {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.holidays[t.In(IST).Format(kHolidayKeyLayout)]
+}
+
+// IsTradingDay returns true if the day of the given time in IST is neither a
+// weekend nor an exchange holiday.
+func (self *MarketCalendar) IsTradingDay(t time.Time) bool {
+	switch t.In(IST).Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !self.IsHoliday(t)
+}
+
+// IsOpen returns true if the market is open at the given time. The close at
+// 15:30 IST is included so that the closing snapshot is not missed.
+func (self *MarketCalendar) IsOpen(t time.Time) bool {
+	if !self.IsTradingDay(t) {
+		return false
+	}
+	open, close := marketSession(t)
+	return !t.Before(open) && !t.After(close)
+}
+
+// NextOpen returns the given time if the market is open at it, otherwise the
+// time at which the market opens next.
+func (self *MarketCalendar) NextOpen(t time.Time) time.Time {
+	if self.IsOpen(t) {
+		return t
+	}
+	day := t.In(IST)
+	for ii := 0; ii < kMaxClosedDays; ii += 1 {
+		open, _ := marketSession(day)
+		if self.IsTradingDay(day) && t.Before(open) {
+			return open
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, IST)
+	}
+	msg := fmt.Sprintf("No trading day within %d days of %v.",
+		kMaxClosedDays, t)
+	glog.Error(msg)
+	return day
+}
+
+// marketSession returns the open and the close of the market on the day of
+// the given time in IST.
+func marketSession(t time.Time) (time.Time, time.Time) {
+	t = t.In(IST)
+	open := time.Date(t.Year(), t.Month(), t.Day(),
+		kMarketOpenHour, kMarketOpenMinute, 0, 0, IST)
+	close := time.Date(t.Year(), t.Month(), t.Day(),
+		kMarketCloseHour, kMarketCloseMinute, 0, 0, IST)
+	return open, close
+}
@@ -3,13 +3,15 @@ package nse
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -22,6 +24,7 @@ const (
 	kOcNiftyStep     = 50
 	kOcFinNifty      = "FINNIFTY"
 	kOcFinNiftyStep  = 50
+	kOcMidcpNifty    = "MIDCPNIFTY"
 
 	kOcFiltered     = "filtered"
 	kOcFilteredData = "data"
@@ -35,11 +38,6 @@ const (
 	kOcRecordsDataStrikePrice = "strikePrice"
 	kOcRecordsDataPe          = "PE"
 	kOcRecordsDataCe          = "CE"
-
-	kOcRowOpenInterest         = "openInterest"
-	kOcRowChangeinOpenInterest = "changeinOpenInterest"
-	kOcRowLastPrice            = "lastPrice"
-	kOcRowTotalTradedVolume    = "totalTradedVolume"
 )
 
 type NseResponse struct {
@@ -57,40 +55,50 @@ func (self *NseResponse) ResponseBuffer() *bytes.Buffer {
 }
 
 type NseOcResponse struct {
-	symbol      string
-	fetchedJson map[string]interface{}
-	step        int32
+	symbol  string
+	payload *OcPayload
+	step    float64
 }
 
 func NewNseOcResponse(
 	symbol string,
-	resp map[string]interface{}) *NseOcResponse {
+	payload *OcPayload) *NseOcResponse {
 	return &NseOcResponse{
-		symbol:      symbol,
-		fetchedJson: resp,
-		step:        0,
+		symbol:  symbol,
+		payload: payload,
+		step:    0,
 	}
 }
 
-func (self *NseOcResponse) SetOptionStep(step int32) {
+// ParseNseOcResponse decodes the option chain JSON of the symbol served by
+// NSE.
+func ParseNseOcResponse(symbol string, data []byte) (*NseOcResponse, error) {
+	payload, err := ParseOcPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewNseOcResponse(symbol, payload), nil
+}
+
+func (self *NseOcResponse) SetOptionStep(step float64) {
 	self.step = step
 }
 
-func (self *NseOcResponse) parseRecords() (map[string]interface{}, error) {
-	recordInt, ok := self.fetchedJson[kOcRecords]
-	if !ok {
+func (self *NseOcResponse) Symbol() string {
+	return self.symbol
+}
+
+func (self *NseOcResponse) Payload() *OcPayload {
+	return self.payload
+}
+
+func (self *NseOcResponse) parseRecords() (*OcRecords, error) {
+	if self.payload == nil || self.payload.Records == nil {
 		msg := fmt.Sprintf("Parsing OC failed. Field %s not found.", kOcRecords)
 		glog.Error(msg)
-		return map[string]interface{}{}, errors.New(msg)
+		return nil, newParseError(msg)
 	}
-	records, ok := recordInt.(map[string]interface{})
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC failed. Incorrect field %s type.",
-			kOcRecords)
-		glog.Error(msg)
-		return map[string]interface{}{}, errors.New(msg)
-	}
-	return records, nil
+	return self.payload.Records, nil
 }
 
 func (self *NseOcResponse) ExpiryDates() ([]string, error) {
@@ -98,11 +106,7 @@ func (self *NseOcResponse) ExpiryDates() ([]string, error) {
 	if err != nil {
 		return []string{}, err
 	}
-	arrayStrInt, err := getArrayField(records, kOcRecordsExpiryDates)
-	if err != nil {
-		return []string{}, err
-	}
-	return convertToStringSlice(arrayStrInt), nil
+	return records.ExpiryDates, nil
 }
 
 func (self *NseOcResponse) Timestamp() (string, error) {
@@ -110,7 +114,16 @@ func (self *NseOcResponse) Timestamp() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return getStrField(records, kOcRecordsTimestamp)
+	return records.Timestamp, nil
+}
+
+// StrikePrices returns the strikes listed across every expiry.
+func (self *NseOcResponse) StrikePrices() ([]float64, error) {
+	records, err := self.parseRecords()
+	if err != nil {
+		return []float64{}, err
+	}
+	return records.StrikePrices, nil
 }
 
 func (self *NseOcResponse) UnderlyingValue() (float64, error) {
@@ -118,32 +131,26 @@ func (self *NseOcResponse) UnderlyingValue() (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return getFloat64Field(records, kOcRecordsUnderlyingValue)
+	return records.UnderlyingValue, nil
 }
 
-func (self *NseOcResponse) parseFiltered() (map[string]interface{}, error) {
-	recordInt, ok := self.fetchedJson[kOcRecords]
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC failed. Field %s not found.", kOcRecords)
-		glog.Error(msg)
-		return map[string]interface{}{}, errors.New(msg)
-	}
-	records, ok := recordInt.(map[string]interface{})
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC failed. Incorrect field %s type.",
-			kOcRecords)
-		glog.Error(msg)
-		return map[string]interface{}{}, errors.New(msg)
+// DataRecords returns the data records of every expiry.
+func (self *NseOcResponse) DataRecords() ([]OcDataRecord, error) {
+	records, err := self.parseRecords()
+	if err != nil {
+		return []OcDataRecord{}, err
 	}
-	return records, nil
+	return records.Data, nil
 }
 
-func (self *NseOcResponse) FilteredData() ([]interface{}, error) {
-	records, err := self.parseFiltered()
-	if err != nil {
-		return []interface{}{}, err
+// FilteredData returns the data records of the nearest expiry.
+func (self *NseOcResponse) FilteredData() ([]OcDataRecord, error) {
+	if self.payload == nil || self.payload.Filtered == nil {
+		msg := fmt.Sprintf("Parsing OC failed. Field %s not found.", kOcFiltered)
+		glog.Error(msg)
+		return []OcDataRecord{}, newParseError(msg)
 	}
-	return getArrayField(records, kOcFilteredData)
+	return self.payload.Filtered.Data, nil
 }
 
 func (self *NseOcResponse) stringExists(arr []string, target string) bool {
@@ -159,153 +166,385 @@ func (self *NseOcResponse) GetExpiryOc(
 	symbol string,
 	expiryDate string) (*NseOc, error) {
 
-	availableExpiries, err := self.ExpiryDates()
+	records, err := self.parseRecords()
 	if err != nil {
-		glog.Error("Failed to fetch available expiry dates for option chain.")
+		glog.Error("Failed to fetch option chain records.")
 		return nil, err
 	}
-	if !self.stringExists(availableExpiries, expiryDate) {
+	if !self.stringExists(records.ExpiryDates, expiryDate) {
 		msg := fmt.Sprintf("No option chain for expiry=%s.", expiryDate)
 		glog.Error(msg)
-		glog.Error(availableExpiries)
+		glog.Error(records.ExpiryDates)
 		return nil, errors.New(msg)
 	}
 
-	timestamp, err := self.Timestamp()
-	if err != nil {
-		msg := fmt.Sprintf("Failed to fetch timestamp.")
-		glog.Error(msg)
-		return nil, errors.New(msg)
-	}
+	expiryDataRecords := self.getExpiryDataRecords(expiryDate, records.Data)
+	return self.newExpiryOc(symbol, expiryDate, records, expiryDataRecords), nil
+}
 
-	underlyingValue, err := self.UnderlyingValue()
+// GetOcSet returns the option chain of every expiry in the response.
+func (self *NseOcResponse) GetOcSet(symbol string) (*NseOcSet, error) {
+	records, err := self.parseRecords()
 	if err != nil {
-		msg := fmt.Sprintf("Failed to fetch underlying asset value.")
-		glog.Error(msg)
-		return nil, errors.New(msg)
+		glog.Error("Failed to fetch option chain records.")
+		return nil, err
 	}
 
-	recordsDataInt, err := self.FilteredData()
-	if err != nil {
-		msg := fmt.Sprintf("Failed to fetch option chain data records.")
-		glog.Error(msg)
-		return nil, errors.New(msg)
+	expiryDataRecords := map[string][]*OcDataRecord{}
+	for ii := range records.Data {
+		expiryDate := records.Data[ii].ExpiryDate
+		expiryDataRecords[expiryDate] =
+			append(expiryDataRecords[expiryDate], &records.Data[ii])
 	}
 
-	expiryDataRecords, err :=
-		self.getExpiryDataRecords(expiryDate, recordsDataInt)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to fetch data records with error=%s", err)
-		glog.Error(msg)
-		return nil, err
+	set := NewNseOcSet(symbol, records.Timestamp, records.UnderlyingValue)
+	for _, expiryDate := range records.ExpiryDates {
+		oc := self.newExpiryOc(symbol, expiryDate, records,
+			expiryDataRecords[expiryDate])
+		if err := set.Add(oc); err != nil {
+			return nil, err
+		}
 	}
+	return set, nil
+}
 
-	oc := NewNseOc(symbol, expiryDate, timestamp, underlyingValue)
+func (self *NseOcResponse) newExpiryOc(
+	symbol string,
+	expiryDate string,
+	records *OcRecords,
+	expiryDataRecords []*OcDataRecord) *NseOc {
+
+	oc := NewNseOc(symbol, expiryDate, records.Timestamp,
+		records.UnderlyingValue)
 	oc.SetOcDataRecords(expiryDataRecords)
-	return oc, nil
+	if self.step > 0 {
+		oc.SetStrikeStep(self.step)
+	} else {
+		oc.SetStrikeStep(inferStrikeStep(oc.Strikes()))
+	}
+	return oc
 }
 
 func (self *NseOcResponse) getExpiryDataRecords(
 	expiryDate string,
-	recordsDataInt []interface{}) ([]map[string]interface{}, error) {
-
-	expiryDataRecords := []map[string]interface{}{}
-	for _, recordInt := range recordsDataInt {
-		dataRecord, ok := recordInt.(map[string]interface{})
-		if !ok {
-			msg := fmt.Sprintf("Unexpected data record.")
-			return []map[string]interface{}{}, errors.New(msg)
-		}
-		dataRecordExpiryDate, err :=
-			getStrField(dataRecord, kOcRecordsDataExpiryDate)
-		if err != nil {
-			msg := fmt.Sprintf("Failed to fetch expiry data from data records.")
-			return []map[string]interface{}{}, errors.New(msg)
-		}
-		if dataRecordExpiryDate != expiryDate {
+	dataRecords []OcDataRecord) []*OcDataRecord {
+
+	expiryDataRecords := []*OcDataRecord{}
+	for ii := range dataRecords {
+		if dataRecords[ii].ExpiryDate != expiryDate {
 			continue
 		}
-		expiryDataRecords = append(expiryDataRecords, dataRecord)
+		expiryDataRecords = append(expiryDataRecords, &dataRecords[ii])
 	}
-	return expiryDataRecords, nil
+	return expiryDataRecords
 }
 
+const (
+	kNseBaseUrl     = "https://www.nseindia.com"
+	kNseArchivesUrl = "https://archives.nseindia.com"
+
+	kNseOcPath                 = "/option-chain"
+	kNseOcIndicesPath          = "/api/option-chain-indices?symbol="
+	kNseOcEquitiesPath         = "/api/option-chain-equities?symbol="
+	kNseFnoParticipantOiPrefix = "/content/nsccl/fao_participant_oi_"
+)
+
+// cookieRefresh is a cookie handshake shared by the goroutines which need a
+// fresh cookie at the same time.
+type cookieRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NSE is a client of the NSE website. It is safe for concurrent use. The
+// session cookies are kept in the cookie jar of the HTTP client, which drops
+// them when they expire.
 type NSE struct {
-	fetchCookie              bool
-	cookie                   *http.Cookie
 	urlOc                    string
 	urlIndex                 string
+	urlEquity                string
 	fnoParticipantOiUrlPreix string
 	session                  *http.Client
-	cookies                  map[string]string
 	headers                  map[string]string
+	rateLimiter              *RateLimiter
+	ocFlights                *ocFlightGroup
+	retryPolicy              RetryPolicy
+
+	mutex sync.Mutex
+	// incremented by every successful cookie handshake, 0 before the first
+	cookieGeneration uint64
+	// the cookie handshake in flight, nil when there is none
+	cookieRefresh *cookieRefresh
+}
+
+// NseOption configures the NSE client created by NewNSE.
+type NseOption func(*NSE)
+
+// WithHTTPClient makes the NSE client send its requests through the given
+// HTTP client. Use it to set a proxy, timeouts or a custom transport. The
+// client keeps the session cookies in the cookie jar of the HTTP client, or
+// in a cookie jar of its own when the HTTP client does not have one. The
+// given HTTP client is not modified.
+func WithHTTPClient(client *http.Client) NseOption {
+	return func(self *NSE) {
+		self.session = client
+	}
+}
+
+// WithTransport makes the NSE client send its requests through the given
+// round tripper.
+func WithTransport(transport http.RoundTripper) NseOption {
+	return func(self *NSE) {
+		self.session = &http.Client{Transport: transport}
+	}
+}
+
+// WithBaseURL points the option chain endpoints at the given server, for
+// example "https://www.nseindia.com" or a local mock server.
+func WithBaseURL(baseUrl string) NseOption {
+	return func(self *NSE) {
+		baseUrl = strings.TrimRight(baseUrl, "/")
+		self.urlOc = baseUrl + kNseOcPath
+		self.urlIndex = baseUrl + kNseOcIndicesPath
+		self.urlEquity = baseUrl + kNseOcEquitiesPath
+	}
+}
+
+// WithArchivesURL points the F&O participant data endpoint at the given
+// server, for example "https://archives.nseindia.com".
+func WithArchivesURL(archivesUrl string) NseOption {
+	return func(self *NSE) {
+		archivesUrl = strings.TrimRight(archivesUrl, "/")
+		self.fnoParticipantOiUrlPreix = archivesUrl + kNseFnoParticipantOiPrefix
+	}
+}
+
+// WithHeaders adds the given headers to every request, replacing the default
+// value of a header with the same name.
+func WithHeaders(headers map[string]string) NseOption {
+	return func(self *NSE) {
+		for k, v := range headers {
+			self.headers[strings.ToLower(k)] = v
+		}
+	}
 }
 
-func NewNSE() *NSE {
-	return &NSE{
-		fetchCookie:              true,
-		cookie:                   nil,
-		urlOc:                    "https://www.nseindia.com/option-chain",
-		urlIndex:                 "https://www.nseindia.com/api/option-chain-indices?symbol=",
-		fnoParticipantOiUrlPreix: "https://archives.nseindia.com/content/nsccl/fao_participant_oi_",
-		session:                  &http.Client{},
-		cookies:                  make(map[string]string),
+// WithRateLimiter makes the NSE client wait on the given rate limiter before
+// every request. Share a rate limiter to limit several clients together.
+func WithRateLimiter(limiter *RateLimiter) NseOption {
+	return func(self *NSE) {
+		self.rateLimiter = limiter
+	}
+}
+
+// WithRateLimit sets the rate limit of the requests to the host, for example
+// "www.nseindia.com". A limit with a Rate of 0 removes the limit. It changes
+// the rate limiter the client has when the option is applied.
+func WithRateLimit(host string, limit RateLimit) NseOption {
+	return func(self *NSE) {
+		self.rateLimiter.SetLimit(host, limit)
+	}
+}
+
+// WithCoalesceWindow sets how long the response of an option chain URL is
+// shared with the callers asking for the same URL after it was fetched. The
+// default is 1 second. With a window of 0 only the callers asking while the
+// URL is being fetched share the response.
+func WithCoalesceWindow(window time.Duration) NseOption {
+	return func(self *NSE) {
+		self.ocFlights.setWindow(window)
+	}
+}
+
+// WithRetryPolicy sets how the client retries the requests NSE does not
+// answer with status 200. The default is DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) NseOption {
+	return func(self *NSE) {
+		self.retryPolicy = policy
+	}
+}
+
+func NewNSE(options ...NseOption) *NSE {
+	self := &NSE{
+		session: &http.Client{},
 		headers: map[string]string{
 			"user-agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Safari/537.36",
 			"accept-language": "en,gu;q=0.9,hi;q=0.8",
 			"accept-encoding": "gzip",
 		},
-	}
+		rateLimiter:      NewRateLimiter(),
+		ocFlights:        newOcFlightGroup(kDefaultCoalesceWindow),
+		retryPolicy:      DefaultRetryPolicy(),
+		mutex:            sync.Mutex{},
+		cookieGeneration: 0,
+		cookieRefresh:    nil,
+	}
+	WithBaseURL(kNseBaseUrl)(self)
+	WithArchivesURL(kNseArchivesUrl)(self)
+	for _, option := range options {
+		option(self)
+	}
+
+	if self.session.Jar == nil {
+		// cookiejar.New never fails without options
+		jar, _ := cookiejar.New(nil)
+		session := *self.session
+		session.Jar = jar
+		self.session = &session
+	}
+	return self
 }
 
 func (self *NSE) FetchCookie() {
+	self.FetchCookieContext(context.Background())
+}
+
+// FetchCookieContext performs the cookie handshake with the option chain page.
+// The request is aborted when the context is cancelled or its deadline
+// expires. Goroutines asking for a handshake while one is in flight wait for
+// it and share its result.
+func (self *NSE) FetchCookieContext(ctx context.Context) error {
+	return self.refreshCookie(ctx, self.currentCookieGeneration())
+}
+
+func (self *NSE) currentCookieGeneration() uint64 {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.cookieGeneration
+}
+
+// refreshCookie performs the cookie handshake unless a handshake completed
+// since the given generation of the cookie was seen, so that the goroutines
+// rejected with the same stale cookie re-handshake only once.
+func (self *NSE) refreshCookie(ctx context.Context, generation uint64) error {
+	self.mutex.Lock()
+	if self.cookieGeneration != generation {
+		self.mutex.Unlock()
+		return nil
+	}
+	refresh := self.cookieRefresh
+	if refresh != nil {
+		self.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-refresh.done:
+			return refresh.err
+		}
+	}
+	refresh = &cookieRefresh{
+		done: make(chan struct{}),
+		err:  nil,
+	}
+	self.cookieRefresh = refresh
+	self.mutex.Unlock()
+
+	refresh.err = self.cookieHandshake(ctx)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if refresh.err == nil {
+		self.cookieGeneration += 1
+	}
+	self.cookieRefresh = nil
+	close(refresh.done)
+	return refresh.err
+}
+
+// cookieHandshake fetches the option chain page, which sets the session
+// cookies in the cookie jar. A status other than 200 is returned as an
+// *HTTPStatusError, as the page then does not set the cookies.
+func (self *NSE) cookieHandshake(ctx context.Context) error {
 	urlStr := self.urlOc
-	req, _ := http.NewRequest("GET", urlStr, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		msg := fmt.Sprintf("Creating request for %s failed with error %s",
+			urlStr, err)
+		glog.Error(msg)
+		return err
+	}
 	for k, v := range self.headers {
 		req.Header.Set(k, v)
 	}
 
+	if err := self.rateLimiter.Wait(ctx, urlStr); err != nil {
+		return err
+	}
 	glog.Info("Fetching URL ", urlStr)
 	resp, err := self.session.Do(req)
 	if err != nil {
 		msg := fmt.Sprintf("Fetching %s failed with error %s\n", urlStr, err)
 		glog.Error(msg)
-		self.fetchCookie = true
-		return
+		return err
 	}
-
-	for _, c := range resp.Cookies() {
-		self.cookies[c.Name] = c.Value
+	if resp.StatusCode != http.StatusOK {
+		statusErr := self.newHTTPStatusError(resp, urlStr)
+		glog.Error(statusErr.Error())
+		return statusErr
 	}
+	resp.Body.Close()
+	return nil
 }
 
+// NewGetRequest returns a request for the URL with the headers of the
+// client. The session cookies are added by the cookie jar of the client when
+// the request is sent.
 func (self *NSE) NewGetRequest(url string) *http.Request {
-	req, _ := http.NewRequest("GET", url, nil)
+	return self.NewGetRequestContext(context.Background(), url)
+}
+
+func (self *NSE) NewGetRequestContext(
+	ctx context.Context,
+	url string) *http.Request {
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	for k, v := range self.headers {
 		req.Header.Set(k, v)
 	}
-	for k, v := range self.cookies {
-		req.AddCookie(&http.Cookie{Name: k, Value: v})
-	}
 	return req
 }
 
 func (self *NSE) FetchUrl(url string) (
 	*http.Response, *NseResponse, error) {
 
+	return self.FetchUrlContext(context.Background(), url)
+}
+
+// FetchUrlContext fetches the URL, retrying as the retry policy of the client
+// decides. A status other than 200 which is not retried is returned as an
+// *HTTPStatusError, which matches ErrUnauthorized, ErrForbidden or
+// ErrRateLimited for their statuses. The context bounds the whole call,
+// including the back-off sleeps between the retries.
+func (self *NSE) FetchUrlContext(ctx context.Context, url string) (
+	*http.Response, *NseResponse, error) {
+
 	var resp *http.Response = nil
 	var err error
 
-	retry := true
-	retryCount := 0
-	for retry {
-		if self.fetchCookie {
-			self.FetchCookie()
+	policy := self.retryPolicy
+	cookieRefreshes := 0
+	retries := 0
+	for attempt := 1; ; attempt += 1 {
+		if err = ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		// generation of the cookie the request is sent with
+		generation := self.currentCookieGeneration()
+		if generation == 0 {
+			if err = self.refreshCookie(ctx, generation); err != nil {
+				msg := fmt.Sprintf("Fetching cookie for URL=%s failed with "+
+					"error=%s", url, err)
+				glog.Error(msg)
+				return nil, nil, err
+			}
+			generation = self.currentCookieGeneration()
 		}
-		self.fetchCookie = false
 
+		if err = self.rateLimiter.Wait(ctx, url); err != nil {
+			return nil, nil, err
+		}
 		glog.Info("Fetching URL ", url)
-		req := self.NewGetRequest(url)
+		req := self.NewGetRequestContext(ctx, url)
 
 		resp, err = self.session.Do(req)
 		if err != nil {
@@ -313,30 +552,37 @@ func (self *NSE) FetchUrl(url string) (
 			glog.Error(msg)
 			return nil, nil, err
 		}
-
-		retry = true
-		errMsg := fmt.Sprintf("Fetching URL=%s failed with status=%d. ",
-			url, resp.StatusCode)
-		switch resp.StatusCode {
-		case http.StatusOK:
-			retry = false
+		if resp.StatusCode == http.StatusOK {
 			break
-		case http.StatusUnauthorized:
-			// http.StatusUnauthorized is 401
-			glog.Error(errMsg, "Fetching Cookie.")
-			self.fetchCookie = true
-		case http.StatusForbidden:
-			// 403
-			glog.Error(errMsg, "Sleeping for 5 minutes.")
-			self.fetchCookie = true
-			time.Sleep(5 * time.Minute)
-		default:
-			retryCount += 1
-			if retryCount >= 5 {
-				return nil, nil, errors.New("Failed with error " + strconv.Itoa(resp.StatusCode))
+		}
+
+		statusErr := self.newHTTPStatusError(resp, url)
+		action := policy.Action(resp.StatusCode)
+		if action == RetryRefreshCookie &&
+			cookieRefreshes >= policy.MaxCookieRefreshes {
+			action = RetryFail
+		}
+		if action == RetryFail || attempt >= policy.MaxAttempts {
+			glog.Error(statusErr.Error())
+			return nil, nil, statusErr
+		}
+
+		if action == RetryRefreshCookie {
+			glog.Error(statusErr.Error(), " Fetching Cookie.")
+			cookieRefreshes += 1
+			if err = self.refreshCookie(ctx, generation); err != nil {
+				msg := fmt.Sprintf("Fetching cookie for URL=%s failed with "+
+					"error=%s", url, err)
+				glog.Error(msg)
+				return nil, nil, err
 			}
-			time.Sleep(1 * time.Second)
-			glog.Error(errMsg, "Retrying...")
+			continue
+		}
+		retries += 1
+		backoff := policy.Backoff(retries)
+		glog.Error(statusErr.Error(), " Retrying in ", backoff, ".")
+		if err = sleepContext(ctx, backoff); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -358,9 +604,38 @@ func (self *NSE) FetchUrl(url string) (
 	return resp, NewNseResponse(respBuf), nil
 }
 
+// newHTTPStatusError reads the start of the body of the response and closes
+// it.
+func (self *NSE) newHTTPStatusError(
+	resp *http.Response,
+	url string) *HTTPStatusError {
+
+	var body *bytes.Buffer
+	var err error
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		body, err = self.readGzipResponse(resp)
+	default:
+		body, err = self.readResponse(resp)
+	}
+	snippet := ""
+	if err == nil {
+		snippet = body.String()
+		if len(snippet) > kErrorBodySnippetLength {
+			snippet = snippet[:kErrorBodySnippetLength]
+		}
+	}
+	return &HTTPStatusError{
+		StatusCode: resp.StatusCode,
+		URL:        url,
+		Body:       snippet,
+	}
+}
+
 func (self *NSE) readGzipResponse(
 	resp *http.Response) (*bytes.Buffer, error) {
 
+	defer resp.Body.Close()
 	reader, err := gzip.NewReader(resp.Body)
 	if err != nil {
 		return nil, err
@@ -384,28 +659,126 @@ func (self *NSE) readResponse(resp *http.Response) (*bytes.Buffer, error) {
 	return bytes.NewBuffer(body), err
 }
 
+// FetchOptionChainUrl fetches the option chain of an index. Concurrent calls
+// for the same symbol, and calls within the coalesce window of a completed
+// fetch, share one request and one parsed response, which must not be
+// modified.
 func (self *NSE) FetchOptionChainUrl(symbol string) (*NseOcResponse, error) {
-	ocUrl := self.urlIndex + url.PathEscape(symbol)
-	_, resp, err := self.FetchUrl(ocUrl)
+	return self.FetchOptionChainUrlContext(context.Background(), symbol)
+}
+
+func (self *NSE) FetchOptionChainUrlContext(
+	ctx context.Context,
+	symbol string) (*NseOcResponse, error) {
+
+	return self.fetchOcUrl(ctx, self.urlIndex, symbol)
+}
+
+// FetchEquityOcUrl fetches the option chain of an F&O stock, for example
+// RELIANCE.
+func (self *NSE) FetchEquityOcUrl(symbol string) (*NseOcResponse, error) {
+	return self.FetchEquityOcUrlContext(context.Background(), symbol)
+}
+
+func (self *NSE) FetchEquityOcUrlContext(
+	ctx context.Context,
+	symbol string) (*NseOcResponse, error) {
+
+	return self.fetchOcUrl(ctx, self.urlEquity, symbol)
+}
+
+// fetchOcUrl fetches and parses the option chain of the symbol, coalescing
+// the fetches of the same URL.
+func (self *NSE) fetchOcUrl(
+	ctx context.Context,
+	urlPrefix string,
+	symbol string) (*NseOcResponse, error) {
+
+	ocUrl := urlPrefix + url.QueryEscape(symbol)
+	return self.ocFlights.do(ctx, ocUrl,
+		func(ctx context.Context) (*NseOcResponse, error) {
+			_, resp, err := self.FetchUrlContext(ctx, ocUrl)
+			if err != nil {
+				msg := fmt.Sprintf(
+					"Fetching OC for symbol=%s failed with err=%s", symbol, err)
+				glog.Error(msg)
+				return nil, err
+			}
+			return ParseNseOcResponse(symbol, resp.ResponseBuffer().Bytes())
+		})
+}
+
+// FetchOptionChain fetches the option chain of an index, for example NIFTY or
+// MIDCPNIFTY, for the given expiry. The strike step is inferred from the
+// strikes of the chain.
+func (self *NSE) FetchOptionChain(symbol string, expiryDate string) (*NseOc, error) {
+	return self.FetchOptionChainContext(context.Background(), symbol, expiryDate)
+}
+
+func (self *NSE) FetchOptionChainContext(
+	ctx context.Context,
+	symbol string,
+	expiryDate string) (*NseOc, error) {
+
+	fetchResp, err := self.FetchOptionChainUrlContext(ctx, symbol)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to fetch %s option chain.", symbol)
+		glog.Error(msg)
+		return nil, err
+	}
+	return fetchResp.GetExpiryOc(symbol, expiryDate)
+}
+
+// FetchOcSet fetches the option chain of every expiry of an index in a single
+// request.
+func (self *NSE) FetchOcSet(symbol string) (*NseOcSet, error) {
+	return self.FetchOcSetContext(context.Background(), symbol)
+}
+
+func (self *NSE) FetchOcSetContext(
+	ctx context.Context,
+	symbol string) (*NseOcSet, error) {
+
+	fetchResp, err := self.FetchOptionChainUrlContext(ctx, symbol)
 	if err != nil {
-		msg := fmt.Sprintf("Fetching OC for symbol=%s failed with err=%s",
-			symbol, err)
+		msg := fmt.Sprintf("Failed to fetch %s option chain.", symbol)
 		glog.Error(msg)
 		return nil, err
 	}
+	return fetchResp.GetOcSet(symbol)
+}
 
-	var jsonData map[string]interface{}
-	err = json.Unmarshal(resp.ResponseBuffer().Bytes(), &jsonData)
+// FetchEquityOcSet fetches the option chain of every expiry of an F&O stock
+// in a single request.
+func (self *NSE) FetchEquityOcSet(symbol string) (*NseOcSet, error) {
+	return self.FetchEquityOcSetContext(context.Background(), symbol)
+}
+
+func (self *NSE) FetchEquityOcSetContext(
+	ctx context.Context,
+	symbol string) (*NseOcSet, error) {
+
+	fetchResp, err := self.FetchEquityOcUrlContext(ctx, symbol)
 	if err != nil {
-		msg := fmt.Sprintf("Parsing OC response failed with error=%s.", err)
+		msg := fmt.Sprintf("Failed to fetch %s option chain.", symbol)
 		glog.Error(msg)
 		return nil, err
 	}
-	return NewNseOcResponse(symbol, jsonData), nil
+	return fetchResp.GetOcSet(symbol)
 }
 
-func (self *NSE) FetchOptionChain(symbol string, expiryDate string) (*NseOc, error) {
-	fetchResp, err := self.FetchOptionChainUrl(symbol)
+// FetchEquityOc fetches the option chain of an F&O stock for the given
+// expiry. The strike step is inferred from the strikes of the chain.
+func (self *NSE) FetchEquityOc(symbol string, expiryDate string) (*NseOc, error) {
+	return self.FetchEquityOcContext(context.Background(), symbol, expiryDate)
+}
+
+func (self *NSE) FetchEquityOcContext(
+	ctx context.Context,
+	symbol string,
+	expiryDate string) (*NseOc, error) {
+
+	fetchResp, err := self.FetchEquityOcUrlContext(ctx, symbol)
 	if err != nil {
 		msg := fmt.Sprintf("Failed to fetch %s option chain.", symbol)
 		glog.Error(msg)
@@ -415,7 +788,14 @@ func (self *NSE) FetchOptionChain(symbol string, expiryDate string) (*NseOc, err
 }
 
 func (self *NSE) FetchBankNiftyOc(expiryDate string) (*NseOc, error) {
-	oc, err := self.FetchOptionChain(kOcBankNifty, expiryDate)
+	return self.FetchBankNiftyOcContext(context.Background(), expiryDate)
+}
+
+func (self *NSE) FetchBankNiftyOcContext(
+	ctx context.Context,
+	expiryDate string) (*NseOc, error) {
+
+	oc, err := self.FetchOptionChainContext(ctx, kOcBankNifty, expiryDate)
 	if err != nil {
 		return nil, err
 	}
@@ -424,7 +804,14 @@ func (self *NSE) FetchBankNiftyOc(expiryDate string) (*NseOc, error) {
 }
 
 func (self *NSE) FetchNiftyOc(expiryDate string) (*NseOc, error) {
-	oc, err := self.FetchOptionChain(kOcNifty, expiryDate)
+	return self.FetchNiftyOcContext(context.Background(), expiryDate)
+}
+
+func (self *NSE) FetchNiftyOcContext(
+	ctx context.Context,
+	expiryDate string) (*NseOc, error) {
+
+	oc, err := self.FetchOptionChainContext(ctx, kOcNifty, expiryDate)
 	if err != nil {
 		return nil, err
 	}
@@ -433,7 +820,14 @@ func (self *NSE) FetchNiftyOc(expiryDate string) (*NseOc, error) {
 }
 
 func (self *NSE) FetchFinNiftyOc(expiryDate string) (*NseOc, error) {
-	oc, err := self.FetchOptionChain(kOcFinNifty, expiryDate)
+	return self.FetchFinNiftyOcContext(context.Background(), expiryDate)
+}
+
+func (self *NSE) FetchFinNiftyOcContext(
+	ctx context.Context,
+	expiryDate string) (*NseOc, error) {
+
+	oc, err := self.FetchOptionChainContext(ctx, kOcFinNifty, expiryDate)
 	if err != nil {
 		return nil, err
 	}
@@ -441,12 +835,30 @@ func (self *NSE) FetchFinNiftyOc(expiryDate string) (*NseOc, error) {
 	return oc, nil
 }
 
+func (self *NSE) FetchMidcpNiftyOc(expiryDate string) (*NseOc, error) {
+	return self.FetchMidcpNiftyOcContext(context.Background(), expiryDate)
+}
+
+func (self *NSE) FetchMidcpNiftyOcContext(
+	ctx context.Context,
+	expiryDate string) (*NseOc, error) {
+
+	return self.FetchOptionChainContext(ctx, kOcMidcpNifty, expiryDate)
+}
+
 func (self *NSE) FetchFOParticipantData(
 	date time.Time) ([]NseFODataRecord, error) {
 
+	return self.FetchFOParticipantDataContext(context.Background(), date)
+}
+
+func (self *NSE) FetchFOParticipantDataContext(
+	ctx context.Context,
+	date time.Time) ([]NseFODataRecord, error) {
+
 	suffix := NseFOData{}.DateToNseFOtData(date)
 	url := fmt.Sprintf("%s%s.csv", self.fnoParticipantOiUrlPreix, suffix)
-	_, data, err := self.FetchUrl(url)
+	_, data, err := self.FetchUrlContext(ctx, url)
 	if err != nil {
 		msg := fmt.Sprintf("Fetching futures data failed with error=%s", err)
 		glog.Error(msg)
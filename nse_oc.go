@@ -3,9 +3,11 @@ package nse
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/golang/glog"
+	"github.com/joshi-prasad/nse/bs"
 )
 
 const (
@@ -16,72 +18,141 @@ const (
 	kMaxCombinedWeight = 8.0
 )
 
+// OptionType tells a call (CE) apart from a put (PE).
+type OptionType string
+
+const (
+	OptionTypeCe OptionType = kOcRecordsDataCe
+	OptionTypePe OptionType = kOcRecordsDataPe
+)
+
 type NseOcRow struct {
-	data map[string]interface{}
+	data *OcContract
 }
 
-func NewNseOcRow(data map[string]interface{}) *NseOcRow {
+func NewNseOcRow(data *OcContract) *NseOcRow {
 	return &NseOcRow{
 		data: data,
 	}
 }
 
+// Contract returns the contract fields as decoded from the NSE response.
+func (self *NseOcRow) Contract() *OcContract {
+	return self.data
+}
+
 func (self *NseOcRow) OpenInterest() int64 {
-	value, err := getFloat64Field(self.data, kOcRowOpenInterest)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to parse open interest.")
-		glog.Error(msg)
-		return 0
-	}
-	return int64(value)
+	return int64(self.data.OpenInterest)
 }
 
 func (self *NseOcRow) ChangeOpenInterest() int64 {
-	value, err := getFloat64Field(self.data, kOcRowChangeinOpenInterest)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to parse change in open interest.")
-		glog.Error(msg)
-		return 0
-	}
-	return int64(value)
+	return int64(self.data.ChangeinOpenInterest)
 }
 
 func (self *NseOcRow) Ltp() float64 {
-	value, err := getFloat64Field(self.data, kOcRowLastPrice)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to parse LTP.")
-		glog.Error(msg)
-		return 0
-	}
-	return value
+	return self.data.LastPrice
 }
 
 func (self *NseOcRow) TradedVolume() int64 {
-	value, err := getFloat64Field(self.data, kOcRowTotalTradedVolume)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to parse traded volume.")
-		glog.Error(msg)
-		return 0
-	}
-	return int64(value)
+	return int64(self.data.TotalTradedVolume)
+}
+
+// PChangeOpenInterest returns the change in open interest in percent.
+func (self *NseOcRow) PChangeOpenInterest() float64 {
+	return self.data.PchangeinOpenInterest
+}
+
+// ImpliedVolatility returns the implied volatility reported by NSE in
+// percent.
+func (self *NseOcRow) ImpliedVolatility() float64 {
+	return self.data.ImpliedVolatility
+}
+
+// Change returns the change in LTP since the previous close.
+func (self *NseOcRow) Change() float64 {
+	return self.data.Change
+}
+
+// PChange returns the change in LTP since the previous close in percent.
+func (self *NseOcRow) PChange() float64 {
+	return self.data.PChange
+}
+
+func (self *NseOcRow) BidPrice() float64 {
+	return self.data.BidPrice
+}
+
+func (self *NseOcRow) AskPrice() float64 {
+	return self.data.AskPrice
+}
+
+func (self *NseOcRow) BidQty() int64 {
+	return int64(self.data.BidQty)
+}
+
+func (self *NseOcRow) AskQty() int64 {
+	return int64(self.data.AskQty)
+}
+
+// BidAskSpread returns the difference between the best ask and the best bid.
+// It is 0 when either side of the book is empty.
+func (self *NseOcRow) BidAskSpread() float64 {
+	return bidAskSpread(self.data.BidPrice, self.data.AskPrice)
+}
+
+func (self *NseOcRow) TotalBuyQuantity() int64 {
+	return int64(self.data.TotalBuyQuantity)
+}
+
+func (self *NseOcRow) TotalSellQuantity() int64 {
+	return int64(self.data.TotalSellQuantity)
+}
+
+// Identifier returns the NSE contract identifier, for example
+// OPTIDXNIFTY01-06-2023CE18500.00.
+func (self *NseOcRow) Identifier() string {
+	return self.data.Identifier
+}
+
+// UnderlyingValue returns the underlying value NSE reported with the contract.
+func (self *NseOcRow) UnderlyingValue() float64 {
+	return self.data.UnderlyingValue
 }
 
 type NseOcRowData struct {
-	StrikePrice int32
+	StrikePrice float64
 	Pe          *NseOcRow
 	Ce          *NseOcRow
 }
 
+// NewNseOcRowData creates the row of a strike. The CE or PE row is left nil
+// when the contract is nil.
 func NewNseOcRowData(
-	StrikePrice int32,
-	ce map[string]interface{},
-	pe map[string]interface{}) *NseOcRowData {
+	StrikePrice float64,
+	ce *OcContract,
+	pe *OcContract) *NseOcRowData {
 
-	return &NseOcRowData{
+	rowData := &NseOcRowData{
 		StrikePrice: StrikePrice,
-		Pe:          NewNseOcRow(pe),
-		Ce:          NewNseOcRow(ce),
+		Pe:          nil,
+		Ce:          nil,
+	}
+	if pe != nil {
+		rowData.Pe = NewNseOcRow(pe)
+	}
+	if ce != nil {
+		rowData.Ce = NewNseOcRow(ce)
 	}
+	return rowData
+}
+
+// Contract returns the CE or the PE row of the strike, nil when the contract
+// is not listed.
+func (self *NseOcRowData) Contract(optionType OptionType) *NseOcRow {
+	if optionType == OptionTypeCe {
+		return self.Ce
+	}
+	return self.Pe
 }
 
 type NseOc struct {
@@ -89,14 +160,25 @@ type NseOc struct {
 	expiryDate      string
 	timestamp       string
 	underlyingValue float64
-	strikeStep      int32
+	strikeStep      float64
 
 	// map of strike price to its row data
-	rows map[int32]*NseOcRowData
+	rows map[float64]*NseOcRowData
+	// strikes present in rows in ascending order
+	strikes []float64
 
 	totalCeOi int64
 	totalPeOi int64
 	pcr       float64
+
+	// risk-free interest rate in percent used to compute the greeks
+	riskFreeRate float64
+	// model used to compute the greeks, the dividend yield in percent for
+	// Merton and the futures price for Black-76 (0 to use the synthetic
+	// futures price)
+	pricingModel  bs.Model
+	dividendYield float64
+	futuresPrice  float64
 }
 
 func NewNseOc(
@@ -111,10 +193,15 @@ func NewNseOc(
 		timestamp:       timestamp,
 		underlyingValue: underlyingValue,
 		strikeStep:      0,
-		rows:            map[int32]*NseOcRowData{},
+		rows:            map[float64]*NseOcRowData{},
+		strikes:         []float64{},
 		totalCeOi:       0,
 		totalPeOi:       0,
 		pcr:             0,
+		riskFreeRate:    kDefaultRiskFreeRate,
+		pricingModel:    bs.BlackScholes,
+		dividendYield:   0,
+		futuresPrice:    0,
 	}
 }
 
@@ -130,32 +217,54 @@ func (self *NseOc) Pcr() float64 {
 	return self.pcr
 }
 
-func (self *NseOc) SetStrikeStep(step int32) {
+// SetStrikeStep records the nominal strike step of the symbol. The strike
+// selection does not depend on it, it walks the strikes present in the chain.
+func (self *NseOc) SetStrikeStep(step float64) {
 	self.strikeStep = step
 }
 
-func (self *NseOc) SetOcDataRecords(
-	dataRecords []map[string]interface{}) {
+func (self *NseOc) StrikeStep() float64 {
+	return self.strikeStep
+}
+
+// Strikes returns the strikes present in the option chain in ascending order.
+func (self *NseOc) Strikes() []float64 {
+	strikes := make([]float64, len(self.strikes))
+	copy(strikes, self.strikes)
+	return strikes
+}
 
+// Row returns the row of the strike, or nil if the strike is not listed.
+func (self *NseOc) Row(strike float64) *NseOcRowData {
+	return self.rows[strike]
+}
+
+func (self *NseOc) setStrikes() {
+	strikes := make([]float64, 0, len(self.rows))
+	for strike := range self.rows {
+		strikes = append(strikes, strike)
+	}
+	sort.Slice(strikes, func(i, j int) bool {
+		return strikes[i] < strikes[j]
+	})
+	self.strikes = strikes
+}
+
+func (self *NseOc) SetOcDataRecords(dataRecords []*OcDataRecord) {
 	for _, record := range dataRecords {
-		strikePriceFloat, err := getFloat64Field(record, kOcRecordsDataStrikePrice)
-		if err != nil {
-			continue
-		}
-		strikePrice := int32(strikePriceFloat)
-		pe, err := getStringToInterfaceMap(record, kOcRecordsDataPe)
-		if err != nil {
-			msg := fmt.Sprintf("PE row absent for strike=%d", strikePrice)
+		strikePrice := record.StrikePrice
+		if record.Pe == nil {
+			msg := fmt.Sprintf("PE row absent for strike=%g", strikePrice)
 			glog.Info(msg)
 		}
-		ce, err := getStringToInterfaceMap(record, kOcRecordsDataCe)
-		if err != nil {
-			msg := fmt.Sprintf("PE row absent for strike=%d", strikePrice)
+		if record.Ce == nil {
+			msg := fmt.Sprintf("CE row absent for strike=%g", strikePrice)
 			glog.Info(msg)
 		}
-		self.rows[strikePrice] = NewNseOcRowData(strikePrice, ce, pe)
+		self.rows[strikePrice] = NewNseOcRowData(strikePrice, record.Ce, record.Pe)
 	}
 
+	self.setStrikes()
 	self.computeAndSetTotalCeOi()
 	self.computeAndSetTotalPeOi()
 	self.setPcr()
@@ -198,38 +307,160 @@ func (self *NseOc) computeAndSetTotalPeOi() {
 	self.totalPeOi = totalOi
 }
 
-func (self *NseOc) AtmStrike() int32 {
-	// glog.Error("********* ", self.underlyingValue, self.strikeStep)
-	return roundToStep(self.underlyingValue, self.strikeStep)
+// AtmStrike returns the listed strike closest to the underlying value. On a
+// tie the lower strike is returned.
+func (self *NseOc) AtmStrike() float64 {
+	index := self.atmIndex()
+	if index < 0 {
+		return roundToStep(self.underlyingValue, self.strikeStep)
+	}
+	return self.strikes[index]
+}
+
+// atmIndex returns the index of the ATM strike in strikes, or -1 when the
+// chain has no strikes.
+func (self *NseOc) atmIndex() int {
+	if len(self.strikes) == 0 {
+		return -1
+	}
+	above := sort.Search(len(self.strikes), func(i int) bool {
+		return self.strikes[i] >= self.underlyingValue
+	})
+	if above == 0 {
+		return 0
+	}
+	if above == len(self.strikes) {
+		return len(self.strikes) - 1
+	}
+	below := above - 1
+	if self.underlyingValue-self.strikes[below] <=
+		self.strikes[above]-self.underlyingValue {
+		return below
+	}
+	return above
 }
 
 func (self *NseOc) UnderlyingValue() float64 {
 	return self.underlyingValue
 }
 
+func (self *NseOc) Symbol() string {
+	return self.symbol
+}
+
 func (self *NseOc) ExpiryDate() string {
 	return self.expiryDate
 }
 
-func (self *NseOc) GetAtmStrikes(totalStrikes int32) []int32 {
-	step := self.strikeStep
-	strikes := make([]int32, totalStrikes)
-	atm := self.AtmStrike()
-	beginValue := atm - ((totalStrikes / 2) * step)
-	for ii := int32(0); ii < totalStrikes; ii += 1 {
-		strikes[ii] = beginValue + (ii * step)
+// ExpiryTime returns the market close of the expiry day in IST.
+func (self *NseOc) ExpiryTime() (time.Time, error) {
+	return ParseExpiryDate(self.expiryDate)
+}
+
+func (self *NseOc) Timestamp() string {
+	return self.timestamp
+}
+
+// Time returns the time at which NSE took the option chain snapshot.
+func (self *NseOc) Time() (time.Time, error) {
+	return ParseOcTimestamp(self.timestamp)
+}
+
+// GetAtmStrikes returns totalStrikes listed strikes centred on the ATM
+// strike. The window is shifted inwards near the ends of the chain, and all
+// the strikes are returned when the chain has fewer than totalStrikes.
+func (self *NseOc) GetAtmStrikes(totalStrikes int32) []float64 {
+	atm := self.atmIndex()
+	if atm < 0 || totalStrikes <= 0 {
+		return []float64{}
 	}
+	return self.strikeWindow(atm-int(totalStrikes/2), int(totalStrikes))
+}
+
+// GetItmStrikes returns up to totalStrikes in-the-money strikes of the given
+// option type closest to the ATM strike, in ascending order. The ATM strike
+// itself is not included.
+func (self *NseOc) GetItmStrikes(
+	optionType OptionType,
+	totalStrikes int32) []float64 {
+
+	atm := self.atmIndex()
+	if atm < 0 || totalStrikes <= 0 {
+		return []float64{}
+	}
+	if optionType == OptionTypeCe {
+		return self.strikesBelow(atm, int(totalStrikes))
+	}
+	return self.strikesAbove(atm, int(totalStrikes))
+}
+
+// GetOtmStrikes returns up to totalStrikes out-of-the-money strikes of the
+// given option type closest to the ATM strike, in ascending order. The ATM
+// strike itself is not included.
+func (self *NseOc) GetOtmStrikes(
+	optionType OptionType,
+	totalStrikes int32) []float64 {
+
+	atm := self.atmIndex()
+	if atm < 0 || totalStrikes <= 0 {
+		return []float64{}
+	}
+	if optionType == OptionTypeCe {
+		return self.strikesAbove(atm, int(totalStrikes))
+	}
+	return self.strikesBelow(atm, int(totalStrikes))
+}
+
+func (self *NseOc) strikesBelow(index int, count int) []float64 {
+	begin := index - count
+	if begin < 0 {
+		begin = 0
+	}
+	return self.Strikes()[begin:index]
+}
+
+func (self *NseOc) strikesAbove(index int, count int) []float64 {
+	end := index + 1 + count
+	if end > len(self.strikes) {
+		end = len(self.strikes)
+	}
+	return self.Strikes()[index+1 : end]
+}
+
+// strikeWindow returns count strikes starting at index begin, shifting the
+// window to stay within the listed strikes.
+func (self *NseOc) strikeWindow(begin int, count int) []float64 {
+	if count > len(self.strikes) {
+		count = len(self.strikes)
+	}
+	if begin+count > len(self.strikes) {
+		begin = len(self.strikes) - count
+	}
+	if begin < 0 {
+		begin = 0
+	}
+	strikes := make([]float64, count)
+	copy(strikes, self.strikes[begin:begin+count])
 	return strikes
 }
 
-func (self *NseOc) NseOcForStrikes(strikes []int32) *NseOc {
+func (self *NseOc) NseOcForStrikes(strikes []float64) *NseOc {
 	oc := NewNseOc(self.symbol, self.expiryDate, self.timestamp,
 		self.underlyingValue)
 
 	for _, strike := range strikes {
-		oc.rows[strike] = self.rows[strike]
+		row, ok := self.rows[strike]
+		if !ok {
+			continue
+		}
+		oc.rows[strike] = row
 	}
 	oc.SetStrikeStep(self.strikeStep)
+	oc.SetRiskFreeRate(self.riskFreeRate)
+	oc.SetPricingModel(self.pricingModel)
+	oc.SetDividendYield(self.dividendYield)
+	oc.SetFuturesPrice(self.futuresPrice)
+	oc.setStrikes()
 	oc.computeAndSetTotalCeOi()
 	oc.computeAndSetTotalPeOi()
 	oc.setPcr()
@@ -237,17 +468,44 @@ func (self *NseOc) NseOcForStrikes(strikes []int32) *NseOc {
 }
 
 type OptionChainShortData struct {
-	Strike int32
-
-	CeOpenInterest       int64
-	CeChangeOpenInterest int64
-	CeTradedVolume       int64
-	CeLtp                float64
+	Strike float64
+	// false when the strike does not list the contract
+	HasCe bool
+	HasPe bool
+
+	CeOpenInterest        int64
+	CeChangeOpenInterest  int64
+	CePChangeOpenInterest float64
+	CeTradedVolume        int64
+	CeLtp                 float64
+	CeChange              float64
+	CePChange             float64
+	CeIv                  float64
+	CeBidPrice            float64
+	CeAskPrice            float64
+	CeBidQty              int64
+	CeAskQty              int64
+	CeTotalBuyQuantity    int64
+	CeTotalSellQuantity   int64
+	CeIdentifier          string
+
+	PeOpenInterest        int64
+	PeChangeOpenInterest  int64
+	PePChangeOpenInterest float64
+	PeTradedVolume        int64
+	PeLtp                 float64
+	PeChange              float64
+	PePChange             float64
+	PeIv                  float64
+	PeBidPrice            float64
+	PeAskPrice            float64
+	PeBidQty              int64
+	PeAskQty              int64
+	PeTotalBuyQuantity    int64
+	PeTotalSellQuantity   int64
+	PeIdentifier          string
 
-	PeOpenInterest       int64
-	PeChangeOpenInterest int64
-	PeTradedVolume       int64
-	PeLtp                float64
+	UnderlyingValue float64
 
 	PcrOi       float64
 	PcrChangeOi float64
@@ -263,12 +521,31 @@ type OptionChainShortData struct {
 	PeChangeOiRank int
 	PeWeightedRank float32
 
+	// Greeks computed from the LTP. Theta is per day, vega and rho are per 1%
+	// change and the solved IV is in percent. IvSolved is false, and the
+	// greeks are 0, when the IV could not be solved from the LTP.
+	CeDelta    float64
+	CeGamma    float64
+	CeTheta    float64
+	CeVega     float64
+	CeRho      float64
+	CeSolvedIv float64
+	CeIvSolved bool
+
+	PeDelta    float64
+	PeGamma    float64
+	PeTheta    float64
+	PeVega     float64
+	PeRho      float64
+	PeSolvedIv float64
+	PeIvSolved bool
+
 	Timestamp int64
 }
 
 type NseShortOc struct {
 	UnderlyingValue float64
-	AtmStrike       int32
+	AtmStrike       float64
 	Oc              []*OptionChainShortData
 
 	TotalCeOi       int64
@@ -287,7 +564,7 @@ type NseShortOc struct {
 func NewNseShortOc(
 	Oc []*OptionChainShortData,
 	UnderlyingValue float64,
-	AtmStrike int32,
+	AtmStrike float64,
 	TotalCeOi int64,
 	TotalCeChangeOi int64,
 	TotalCeVolume int64,
@@ -311,24 +588,55 @@ func NewNseShortOc(
 	}
 }
 
+// CeBidAskSpread returns the CE ask minus bid. It is 0 when either side of the
+// book is empty.
+func (self *OptionChainShortData) CeBidAskSpread() float64 {
+	return bidAskSpread(self.CeBidPrice, self.CeAskPrice)
+}
+
+// PeBidAskSpread returns the PE ask minus bid. It is 0 when either side of the
+// book is empty.
+func (self *OptionChainShortData) PeBidAskSpread() float64 {
+	return bidAskSpread(self.PeBidPrice, self.PeAskPrice)
+}
+
+func bidAskSpread(bid float64, ask float64) float64 {
+	if bid <= 0 || ask <= 0 {
+		return 0
+	}
+	return ask - bid
+}
+
 func (self *OptionChainShortData) String() string {
-	return fmt.Sprintf("Strike: %d, CE Open Interest: %d, "+
+	return fmt.Sprintf("Strike: %g, CE Open Interest: %d, "+
 		"CE Change Open Interest: %d, PE Open Interest: %d, "+
 		"PE Change Open Interest: %d",
 		self.Strike, self.CeOpenInterest, self.CeChangeOpenInterest,
 		self.PeOpenInterest, self.PeChangeOpenInterest)
 }
 
-func (self *NseOc) GetStrikes(beginStrike int32, totalStrikes int32) []int32 {
-	step := self.strikeStep
-	strikes := make([]int32, totalStrikes)
-	for ii := int32(0); ii < totalStrikes; ii += 1 {
-		strikes[ii] = beginStrike + (ii * step)
+// GetStrikes returns totalStrikes listed strikes starting at the first strike
+// at or above beginStrike.
+func (self *NseOc) GetStrikes(
+	beginStrike float64,
+	totalStrikes int32) []float64 {
+
+	if totalStrikes <= 0 {
+		return []float64{}
+	}
+	begin := sort.Search(len(self.strikes), func(i int) bool {
+		return self.strikes[i] >= beginStrike
+	})
+	end := begin + int(totalStrikes)
+	if end > len(self.strikes) {
+		end = len(self.strikes)
 	}
+	strikes := make([]float64, end-begin)
+	copy(strikes, self.strikes[begin:end])
 	return strikes
 }
 
-func (self *NseOc) GetOptionChainShortData(strikes []int32) *NseShortOc {
+func (self *NseOc) GetOptionChainShortData(strikes []float64) *NseShortOc {
 	totalCeOi := int64(0)
 	totalCeVolume := int64(0)
 	totalCeChangeOi := int64(0)
@@ -346,6 +654,8 @@ func (self *NseOc) GetOptionChainShortData(strikes []int32) *NseShortOc {
 
 		data := &OptionChainShortData{
 			Strike:               strike,
+			HasCe:                row.Ce != nil,
+			HasPe:                row.Pe != nil,
 			CeOpenInterest:       0,
 			CeChangeOpenInterest: 0,
 			CeTradedVolume:       0,
@@ -357,18 +667,41 @@ func (self *NseOc) GetOptionChainShortData(strikes []int32) *NseShortOc {
 			PcrOi:                0,
 			PcrChangeOi:          0,
 			PcrVolume:            0,
+			UnderlyingValue:      self.underlyingValue,
 		}
 		if ce := row.Ce; ce != nil {
 			data.CeOpenInterest = ce.OpenInterest()
 			data.CeChangeOpenInterest = ce.ChangeOpenInterest()
+			data.CePChangeOpenInterest = ce.PChangeOpenInterest()
 			data.CeTradedVolume = ce.TradedVolume()
 			data.CeLtp = ce.Ltp()
+			data.CeChange = ce.Change()
+			data.CePChange = ce.PChange()
+			data.CeIv = ce.ImpliedVolatility()
+			data.CeBidPrice = ce.BidPrice()
+			data.CeAskPrice = ce.AskPrice()
+			data.CeBidQty = ce.BidQty()
+			data.CeAskQty = ce.AskQty()
+			data.CeTotalBuyQuantity = ce.TotalBuyQuantity()
+			data.CeTotalSellQuantity = ce.TotalSellQuantity()
+			data.CeIdentifier = ce.Identifier()
 		}
 		if pe := row.Pe; pe != nil {
 			data.PeOpenInterest = pe.OpenInterest()
 			data.PeChangeOpenInterest = pe.ChangeOpenInterest()
+			data.PePChangeOpenInterest = pe.PChangeOpenInterest()
 			data.PeTradedVolume = pe.TradedVolume()
 			data.PeLtp = pe.Ltp()
+			data.PeChange = pe.Change()
+			data.PePChange = pe.PChange()
+			data.PeIv = pe.ImpliedVolatility()
+			data.PeBidPrice = pe.BidPrice()
+			data.PeAskPrice = pe.AskPrice()
+			data.PeBidQty = pe.BidQty()
+			data.PeAskQty = pe.AskQty()
+			data.PeTotalBuyQuantity = pe.TotalBuyQuantity()
+			data.PeTotalSellQuantity = pe.TotalSellQuantity()
+			data.PeIdentifier = pe.Identifier()
 		}
 		data.PcrOi = computePcr(data.PeOpenInterest, data.CeOpenInterest)
 		data.PcrChangeOi = computePcr(data.PeChangeOpenInterest, data.CeChangeOpenInterest)
@@ -444,7 +777,7 @@ func (self *NseShortOc) PrintTable() {
 		}
 
 		// Print CE and PE values with color formatting
-		fmt.Printf("%s %c %-10d %s %s %-10.2f %-10.2f %-10.2f %s %-8d %-8d %-8d "+
+		fmt.Printf("%s %c %-10g %s %s %-10.2f %-10.2f %-10.2f %s %-8d %-8d %-8d "+
 			"%s %-8d %-8d %-8d %-2s %-10s %-10s\n",
 			ceColor(
 				fmt.Sprintf("%-10.2f %-6d %-12d %-12d", row.CeLtp, row.CeOpenInterest,
@@ -460,6 +793,11 @@ func (self *NseShortOc) PrintTable() {
 			peRankColor(fmt.Sprintf("%-0.1f", row.PeWeightedRank)))
 	}
 
+	if self.hasGreeks() {
+		fmt.Println()
+		self.PrintGreeksTable()
+	}
+
 	// Print the total values
 	fmt.Println("\nTotals:")
 	fmt.Printf("Total CE Open Interest:       %-10d\n", self.TotalCeOi)
@@ -474,6 +812,58 @@ func (self *NseShortOc) PrintTable() {
 	fmt.Printf("PCR Change OI:              %-10f\n", self.PcrChangeOi)
 }
 
+// hasGreeks tells if the IV of any contract was solved, which is only done
+// by GetOptionChainShortDataWithGreeks.
+func (self *NseShortOc) hasGreeks() bool {
+	for _, row := range self.Oc {
+		if row.CeIvSolved || row.PeIvSolved {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintGreeksTable prints the solved IV and the greeks of every strike.
+func (self *NseShortOc) PrintGreeksTable() {
+	fmt.Printf("%-8s %-8s %-8s %-8s %-8s %-8s %-8s %s %-8s %-8s %-8s "+
+		"%-8s %-8s %-8s\n",
+		"CE_IV", "CE_DELTA", "CE_GAMMA", "CE_THETA", "CE_VEGA", "CE_RHO",
+		"Strike", "||", "PE_IV", "PE_DELTA", "PE_GAMMA", "PE_THETA", "PE_VEGA",
+		"PE_RHO")
+
+	for _, row := range self.Oc {
+		atmChar := ' '
+		if row.Strike == self.AtmStrike {
+			atmChar = '*'
+		}
+		fmt.Printf("%s %c%-7g %s %s\n",
+			formatGreeks(row.CeIvSolved, row.CeSolvedIv, row.CeDelta,
+				row.CeGamma, row.CeTheta, row.CeVega, row.CeRho),
+			atmChar, row.Strike, "||",
+			formatGreeks(row.PeIvSolved, row.PeSolvedIv, row.PeDelta,
+				row.PeGamma, row.PeTheta, row.PeVega, row.PeRho))
+	}
+}
+
+// formatGreeks formats the IV and the greeks of a side of a strike, or
+// leaves the columns empty when the IV was not solved.
+func formatGreeks(
+	solved bool,
+	iv float64,
+	delta float64,
+	gamma float64,
+	theta float64,
+	vega float64,
+	rho float64) string {
+
+	if !solved {
+		return fmt.Sprintf("%-8s %-8s %-8s %-8s %-8s %-8s",
+			"-", "-", "-", "-", "-", "-")
+	}
+	return fmt.Sprintf("%-8.2f %-8.4f %-8.5f %-8.2f %-8.2f %-8.2f",
+		iv, delta, gamma, theta, vega, rho)
+}
+
 func computePcr(pe int64, ce int64) float64 {
 	if ce <= 0 {
 		return 0
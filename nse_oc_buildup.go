@@ -0,0 +1,263 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+)
+
+// Buildup classifies the change in a contract between two snapshots by the
+// direction of its price and of its open interest.
+type Buildup int
+
+const (
+	// The price or the open interest did not change.
+	BuildupNone Buildup = iota
+	// Price up and open interest up, new buyers.
+	LongBuildup
+	// Price down and open interest up, new writers.
+	ShortBuildup
+	// Price up and open interest down, writers exiting.
+	ShortCovering
+	// Price down and open interest down, buyers exiting.
+	LongUnwinding
+)
+
+var kBuildups = []Buildup{
+	LongBuildup, ShortBuildup, ShortCovering, LongUnwinding,
+}
+
+func (self Buildup) String() string {
+	switch self {
+	case LongBuildup:
+		return "Long Buildup"
+	case ShortBuildup:
+		return "Short Buildup"
+	case ShortCovering:
+		return "Short Covering"
+	case LongUnwinding:
+		return "Long Unwinding"
+	default:
+		return "None"
+	}
+}
+
+// ClassifyBuildup classifies a change in price and open interest.
+func ClassifyBuildup(priceChange float64, oiChange int64) Buildup {
+	switch {
+	case priceChange == 0 || oiChange == 0:
+		return BuildupNone
+	case priceChange > 0 && oiChange > 0:
+		return LongBuildup
+	case priceChange < 0 && oiChange > 0:
+		return ShortBuildup
+	case priceChange > 0:
+		return ShortCovering
+	default:
+		return LongUnwinding
+	}
+}
+
+// Moneyness places a contract relative to the ATM strike.
+type Moneyness int
+
+const (
+	Itm Moneyness = iota
+	Atm
+	Otm
+)
+
+func (self Moneyness) String() string {
+	switch self {
+	case Itm:
+		return "ITM"
+	case Atm:
+		return "ATM"
+	default:
+		return "OTM"
+	}
+}
+
+// ContractBuildup is the change in a CE or a PE between two snapshots.
+type ContractBuildup struct {
+	Buildup     Buildup
+	Moneyness   Moneyness
+	PriceChange float64
+	OiChange    int64
+}
+
+// StrikeBuildup is the change in the CE and the PE of a strike. A side is
+// nil when the contract is missing from either snapshot.
+type StrikeBuildup struct {
+	Strike float64
+	Ce     *ContractBuildup
+	Pe     *ContractBuildup
+}
+
+// BuildupBucket aggregates the contracts of one side and moneyness by their
+// buildup, counting the contracts and summing their change in open interest.
+type BuildupBucket struct {
+	Contracts map[Buildup]int
+	OiChange  map[Buildup]int64
+}
+
+func newBuildupBucket() *BuildupBucket {
+	return &BuildupBucket{
+		Contracts: map[Buildup]int{},
+		OiChange:  map[Buildup]int64{},
+	}
+}
+
+func (self *BuildupBucket) add(contract *ContractBuildup) {
+	self.Contracts[contract.Buildup] += 1
+	self.OiChange[contract.Buildup] += contract.OiChange
+}
+
+// BuildupReport is the buildup of every strike listed in both snapshots,
+// in ascending order of strike, and its aggregate by moneyness for each side.
+// The moneyness is relative to the ATM strike of the later snapshot.
+type BuildupReport struct {
+	Symbol        string
+	ExpiryDate    string
+	PrevTimestamp string
+	Timestamp     string
+	AtmStrike     float64
+
+	Strikes []*StrikeBuildup
+	Ce      map[Moneyness]*BuildupBucket
+	Pe      map[Moneyness]*BuildupBucket
+}
+
+// Buildup classifies the change in every CE and PE since the prev snapshot
+// of the same symbol and expiry.
+func (self *NseOc) Buildup(prev *NseOc) (*BuildupReport, error) {
+	if prev == nil {
+		msg := fmt.Sprintf("No previous snapshot to compare %s expiry=%s "+
+			"with.", self.symbol, self.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	if prev.symbol != self.symbol || prev.expiryDate != self.expiryDate {
+		msg := fmt.Sprintf("Cannot compare %s expiry=%s with %s expiry=%s.",
+			self.symbol, self.expiryDate, prev.symbol, prev.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	atmStrike := self.AtmStrike()
+	report := &BuildupReport{
+		Symbol:        self.symbol,
+		ExpiryDate:    self.expiryDate,
+		PrevTimestamp: prev.timestamp,
+		Timestamp:     self.timestamp,
+		AtmStrike:     atmStrike,
+		Strikes:       []*StrikeBuildup{},
+		Ce:            map[Moneyness]*BuildupBucket{},
+		Pe:            map[Moneyness]*BuildupBucket{},
+	}
+	for _, moneyness := range []Moneyness{Itm, Atm, Otm} {
+		report.Ce[moneyness] = newBuildupBucket()
+		report.Pe[moneyness] = newBuildupBucket()
+	}
+
+	for _, strike := range self.strikes {
+		prevRow, ok := prev.rows[strike]
+		if !ok {
+			continue
+		}
+		row := self.rows[strike]
+		strikeBuildup := &StrikeBuildup{
+			Strike: strike,
+			Ce:     contractBuildup(row.Ce, prevRow.Ce),
+			Pe:     contractBuildup(row.Pe, prevRow.Pe),
+		}
+		if ce := strikeBuildup.Ce; ce != nil {
+			ce.Moneyness = strikeMoneyness(OptionTypeCe, strike, atmStrike)
+			report.Ce[ce.Moneyness].add(ce)
+		}
+		if pe := strikeBuildup.Pe; pe != nil {
+			pe.Moneyness = strikeMoneyness(OptionTypePe, strike, atmStrike)
+			report.Pe[pe.Moneyness].add(pe)
+		}
+		report.Strikes = append(report.Strikes, strikeBuildup)
+	}
+	return report, nil
+}
+
+func contractBuildup(row *NseOcRow, prevRow *NseOcRow) *ContractBuildup {
+	if row == nil || prevRow == nil {
+		return nil
+	}
+	priceChange := row.Ltp() - prevRow.Ltp()
+	oiChange := row.OpenInterest() - prevRow.OpenInterest()
+	return &ContractBuildup{
+		Buildup:     ClassifyBuildup(priceChange, oiChange),
+		Moneyness:   Atm,
+		PriceChange: priceChange,
+		OiChange:    oiChange,
+	}
+}
+
+// strikeMoneyness returns the moneyness of the contract. A CE is in the money
+// below the ATM strike and a PE above it.
+func strikeMoneyness(
+	optionType OptionType,
+	strike float64,
+	atmStrike float64) Moneyness {
+
+	if strike == atmStrike {
+		return Atm
+	}
+	if (strike < atmStrike) == (optionType == OptionTypeCe) {
+		return Itm
+	}
+	return Otm
+}
+
+func (self *BuildupReport) PrintTable() {
+	fmt.Printf("%s expiry=%s from %s to %s\n", self.Symbol, self.ExpiryDate,
+		self.PrevTimestamp, self.Timestamp)
+	fmt.Printf("%-16s %-10s %-10s %-8s %s %-16s %-10s %-10s\n",
+		"CE_BUILDUP", "CE_LTP_CHG", "CE_OI_CHG", "Strike", "||",
+		"PE_BUILDUP", "PE_LTP_CHG", "PE_OI_CHG")
+	for _, strike := range self.Strikes {
+		atmChar := ' '
+		if strike.Strike == self.AtmStrike {
+			atmChar = '*'
+		}
+		ce := strike.Ce
+		if ce == nil {
+			ce = &ContractBuildup{}
+		}
+		pe := strike.Pe
+		if pe == nil {
+			pe = &ContractBuildup{}
+		}
+		fmt.Printf("%-16s %-10.2f %-10d %c%-7g %s %-16s %-10.2f %-10d\n",
+			ce.Buildup, ce.PriceChange, ce.OiChange, atmChar, strike.Strike,
+			"||", pe.Buildup, pe.PriceChange, pe.OiChange)
+	}
+
+	fmt.Printf("\n%-4s %-4s", "SIDE", "")
+	for _, buildup := range kBuildups {
+		fmt.Printf(" %-24s", buildup)
+	}
+	fmt.Println()
+	for _, side := range []OptionType{OptionTypeCe, OptionTypePe} {
+		buckets := self.Ce
+		if side == OptionTypePe {
+			buckets = self.Pe
+		}
+		for _, moneyness := range []Moneyness{Itm, Atm, Otm} {
+			bucket := buckets[moneyness]
+			fmt.Printf("%-4s %-4s", side, moneyness)
+			for _, buildup := range kBuildups {
+				cell := fmt.Sprintf("%d (%d)", bucket.Contracts[buildup],
+					bucket.OiChange[buildup])
+				fmt.Printf(" %-24s", cell)
+			}
+			fmt.Println()
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// ContractDiff is the change in a CE or a PE between two snapshots. The IV
+// change is in percent points.
+type ContractDiff struct {
+	OpenInterest       int64
+	ChangeOpenInterest int64
+	TradedVolume       int64
+	Ltp                float64
+	Iv                 float64
+}
+
+// StrikeDiff is the change in the CE and the PE of a strike. A side is nil
+// when the contract is missing from either snapshot.
+type StrikeDiff struct {
+	Strike float64
+	Ce     *ContractDiff
+	Pe     *ContractDiff
+}
+
+// OcDiff is the change in an option chain between two snapshots, so callers
+// can see what changed over the interval and not only since the day opened.
+// The totals are the changes in the totals of the whole option chain.
+type OcDiff struct {
+	Symbol        string
+	ExpiryDate    string
+	PrevTimestamp string
+	Timestamp     string
+	// 0 when the timestamps are not known
+	Interval time.Duration
+
+	UnderlyingValueChange float64
+	// strikes listed in both snapshots in ascending order of strike
+	Strikes []*StrikeDiff
+
+	TotalCeOi       int64
+	TotalCeChangeOi int64
+	TotalCeVolume   int64
+
+	TotalPeOi       int64
+	TotalPeChangeOi int64
+	TotalPeVolume   int64
+
+	PrevPcr   float64
+	Pcr       float64
+	PcrChange float64
+}
+
+// Diff returns the change in the option chain since the prev snapshot of the
+// same symbol and expiry.
+func (self *NseOc) Diff(prev *NseOc) (*OcDiff, error) {
+	if prev == nil {
+		msg := fmt.Sprintf("No previous snapshot to compare %s expiry=%s "+
+			"with.", self.symbol, self.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	if prev.symbol != self.symbol || prev.expiryDate != self.expiryDate {
+		msg := fmt.Sprintf("Cannot compare %s expiry=%s with %s expiry=%s.",
+			self.symbol, self.expiryDate, prev.symbol, prev.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	diff := &OcDiff{
+		Symbol:                self.symbol,
+		ExpiryDate:            self.expiryDate,
+		PrevTimestamp:         prev.timestamp,
+		Timestamp:             self.timestamp,
+		Interval:              0,
+		UnderlyingValueChange: self.underlyingValue - prev.underlyingValue,
+		Strikes:               []*StrikeDiff{},
+		TotalCeOi:             self.totalCeOi - prev.totalCeOi,
+		TotalPeOi:             self.totalPeOi - prev.totalPeOi,
+		PrevPcr:               prev.pcr,
+		Pcr:                   self.pcr,
+		PcrChange:             self.pcr - prev.pcr,
+	}
+	now, nowErr := self.Time()
+	then, thenErr := prev.Time()
+	if nowErr == nil && thenErr == nil {
+		diff.Interval = now.Sub(then)
+	}
+
+	ceChangeOi, ceVolume, peChangeOi, peVolume := self.rowTotals()
+	prevCeChangeOi, prevCeVolume, prevPeChangeOi, prevPeVolume :=
+		prev.rowTotals()
+	diff.TotalCeChangeOi = ceChangeOi - prevCeChangeOi
+	diff.TotalCeVolume = ceVolume - prevCeVolume
+	diff.TotalPeChangeOi = peChangeOi - prevPeChangeOi
+	diff.TotalPeVolume = peVolume - prevPeVolume
+
+	for _, strike := range self.strikes {
+		prevRow, ok := prev.rows[strike]
+		if !ok {
+			continue
+		}
+		row := self.rows[strike]
+		diff.Strikes = append(diff.Strikes, &StrikeDiff{
+			Strike: strike,
+			Ce:     rowDiff(row.Ce, prevRow.Ce),
+			Pe:     rowDiff(row.Pe, prevRow.Pe),
+		})
+	}
+	return diff, nil
+}
+
+// rowTotals returns the total change in open interest and the total traded
+// volume of the CEs and of the PEs.
+func (self *NseOc) rowTotals() (int64, int64, int64, int64) {
+	ceChangeOi := int64(0)
+	ceVolume := int64(0)
+	peChangeOi := int64(0)
+	peVolume := int64(0)
+	for _, row := range self.rows {
+		if row.Ce != nil {
+			ceChangeOi += row.Ce.ChangeOpenInterest()
+			ceVolume += row.Ce.TradedVolume()
+		}
+		if row.Pe != nil {
+			peChangeOi += row.Pe.ChangeOpenInterest()
+			peVolume += row.Pe.TradedVolume()
+		}
+	}
+	return ceChangeOi, ceVolume, peChangeOi, peVolume
+}
+
+func rowDiff(row *NseOcRow, prevRow *NseOcRow) *ContractDiff {
+	if row == nil || prevRow == nil {
+		return nil
+	}
+	changeOi := row.ChangeOpenInterest() - prevRow.ChangeOpenInterest()
+	iv := row.ImpliedVolatility() - prevRow.ImpliedVolatility()
+	return &ContractDiff{
+		OpenInterest:       row.OpenInterest() - prevRow.OpenInterest(),
+		ChangeOpenInterest: changeOi,
+		TradedVolume:       row.TradedVolume() - prevRow.TradedVolume(),
+		Ltp:                row.Ltp() - prevRow.Ltp(),
+		Iv:                 iv,
+	}
+}
+
+// Diff returns the change in the short option chain since the prev snapshot.
+// The short option chain does not carry the symbol or the timestamps, so they
+// are left empty. The PCR is the PCR of the open interest.
+func (self *NseShortOc) Diff(prev *NseShortOc) (*OcDiff, error) {
+	if prev == nil {
+		msg := "No previous snapshot to compare the option chain with."
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	diff := &OcDiff{
+		Symbol:                "",
+		ExpiryDate:            "",
+		PrevTimestamp:         "",
+		Timestamp:             "",
+		Interval:              0,
+		UnderlyingValueChange: self.UnderlyingValue - prev.UnderlyingValue,
+		Strikes:               []*StrikeDiff{},
+		TotalCeOi:             self.TotalCeOi - prev.TotalCeOi,
+		TotalCeChangeOi:       self.TotalCeChangeOi - prev.TotalCeChangeOi,
+		TotalCeVolume:         self.TotalCeVolume - prev.TotalCeVolume,
+		TotalPeOi:             self.TotalPeOi - prev.TotalPeOi,
+		TotalPeChangeOi:       self.TotalPeChangeOi - prev.TotalPeChangeOi,
+		TotalPeVolume:         self.TotalPeVolume - prev.TotalPeVolume,
+		PrevPcr:               prev.PcrOi,
+		Pcr:                   self.PcrOi,
+		PcrChange:             self.PcrOi - prev.PcrOi,
+	}
+
+	prevRows := make(map[float64]*OptionChainShortData, len(prev.Oc))
+	for _, row := range prev.Oc {
+		prevRows[row.Strike] = row
+	}
+	for _, row := range self.Oc {
+		prevRow, ok := prevRows[row.Strike]
+		if !ok {
+			continue
+		}
+		strikeDiff := &StrikeDiff{
+			Strike: row.Strike,
+			Ce:     nil,
+			Pe:     nil,
+		}
+		if row.HasCe && prevRow.HasCe {
+			strikeDiff.Ce = &ContractDiff{
+				OpenInterest: row.CeOpenInterest - prevRow.CeOpenInterest,
+				ChangeOpenInterest: row.CeChangeOpenInterest -
+					prevRow.CeChangeOpenInterest,
+				TradedVolume: row.CeTradedVolume - prevRow.CeTradedVolume,
+				Ltp:          row.CeLtp - prevRow.CeLtp,
+				Iv:           row.CeIv - prevRow.CeIv,
+			}
+		}
+		if row.HasPe && prevRow.HasPe {
+			strikeDiff.Pe = &ContractDiff{
+				OpenInterest: row.PeOpenInterest - prevRow.PeOpenInterest,
+				ChangeOpenInterest: row.PeChangeOpenInterest -
+					prevRow.PeChangeOpenInterest,
+				TradedVolume: row.PeTradedVolume - prevRow.PeTradedVolume,
+				Ltp:          row.PeLtp - prevRow.PeLtp,
+				Iv:           row.PeIv - prevRow.PeIv,
+			}
+		}
+		diff.Strikes = append(diff.Strikes, strikeDiff)
+	}
+	return diff, nil
+}
+
+func (self *OcDiff) PrintTable() {
+	if self.Symbol != "" {
+		fmt.Printf("%s %s from %s to %s (%v)\n", self.Symbol, self.ExpiryDate,
+			self.PrevTimestamp, self.Timestamp, self.Interval)
+	}
+	fmt.Printf("Underlying %+.2f PCR %.2f -> %.2f (%+.2f)\n",
+		self.UnderlyingValueChange, self.PrevPcr, self.Pcr, self.PcrChange)
+	fmt.Printf("CE OI %+d ChangeOI %+d Volume %+d\n", self.TotalCeOi,
+		self.TotalCeChangeOi, self.TotalCeVolume)
+	fmt.Printf("PE OI %+d ChangeOI %+d Volume %+d\n", self.TotalPeOi,
+		self.TotalPeChangeOi, self.TotalPeVolume)
+	fmt.Printf("%-10s %-10s %-8s %-8s %-8s %s %-10s %-10s %-8s %-8s\n",
+		"CE_OI", "CE_VOLUME", "CE_LTP", "CE_IV", "Strike", "||", "PE_OI",
+		"PE_VOLUME", "PE_LTP", "PE_IV")
+	for _, strike := range self.Strikes {
+		ce := strike.Ce
+		if ce == nil {
+			ce = &ContractDiff{}
+		}
+		pe := strike.Pe
+		if pe == nil {
+			pe = &ContractDiff{}
+		}
+		fmt.Printf("%-+10d %-+10d %-+8.2f %-+8.2f %-8g %s %-+10d %-+10d "+
+			"%-+8.2f %-+8.2f\n",
+			ce.OpenInterest, ce.TradedVolume, ce.Ltp, ce.Iv, strike.Strike,
+			"||", pe.OpenInterest, pe.TradedVolume, pe.Ltp, pe.Iv)
+	}
+}
@@ -0,0 +1,287 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/glog"
+	"github.com/joshi-prasad/nse/bs"
+)
+
+const (
+	// Default risk-free interest rate in percent, roughly the yield of the
+	// 91 day treasury bill.
+	kDefaultRiskFreeRate = 7.0
+
+	kMinutesPerDay = 24 * 60
+)
+
+// StrikeGreeks holds the greeks of the CE and the PE of a strike. The IV of
+// the greeks is solved from the LTP and is a fraction, not a percent. A side
+// is nil when the contract is not listed or its IV could not be solved.
+type StrikeGreeks struct {
+	Strike float64
+	Ce     *bs.OptionGreeks
+	Pe     *bs.OptionGreeks
+}
+
+// SetRiskFreeRate sets the risk-free interest rate, in percent, used to
+// compute the greeks.
+func (self *NseOc) SetRiskFreeRate(rate float64) {
+	self.riskFreeRate = rate
+}
+
+func (self *NseOc) RiskFreeRate() float64 {
+	return self.riskFreeRate
+}
+
+// SetPricingModel sets the model used to compute the greeks. Index options
+// are usually priced with Black-76 off the futures and stock options with
+// Merton using the dividend yield. The default is Black-Scholes on the spot.
+func (self *NseOc) SetPricingModel(model bs.Model) {
+	self.pricingModel = model
+}
+
+func (self *NseOc) PricingModel() bs.Model {
+	return self.pricingModel
+}
+
+// SetDividendYield sets the continuous dividend yield, in percent, used by
+// the Merton model.
+func (self *NseOc) SetDividendYield(yield float64) {
+	self.dividendYield = yield
+}
+
+func (self *NseOc) DividendYield() float64 {
+	return self.dividendYield
+}
+
+// SetFuturesPrice sets the price of the futures expiring with the option
+// chain, used by the Black-76 model. When it is 0 the synthetic futures price
+// is used instead.
+func (self *NseOc) SetFuturesPrice(price float64) {
+	self.futuresPrice = price
+}
+
+func (self *NseOc) FuturesPrice() float64 {
+	return self.futuresPrice
+}
+
+// SyntheticFuturesPrice returns the futures price implied by the put-call
+// parity, F = K + (C - P) * exp(rT), at the strike where the CE and the PE
+// LTPs are closest. Only strikes where both sides traded are considered.
+func (self *NseOc) SyntheticFuturesPrice() (float64, error) {
+	daysToExpiry, err := self.DaysToExpiry()
+	if err != nil {
+		return 0, err
+	}
+	growth := math.Exp(self.riskFreeRate / 100 * daysToExpiry / 365)
+
+	found := false
+	bestDiff := 0.0
+	futuresPrice := 0.0
+	for _, strike := range self.strikes {
+		row := self.rows[strike]
+		if row.Ce == nil || row.Pe == nil {
+			continue
+		}
+		ceLtp := row.Ce.Ltp()
+		peLtp := row.Pe.Ltp()
+		if ceLtp <= 0 || peLtp <= 0 {
+			continue
+		}
+		diff := ceLtp - peLtp
+		if !found || math.Abs(diff) < math.Abs(bestDiff) {
+			found = true
+			bestDiff = diff
+			futuresPrice = strike + diff*growth
+		}
+	}
+	if !found {
+		msg := fmt.Sprintf("No strike of %s expiry=%s has both CE and PE "+
+			"prices to derive the synthetic futures price.", self.symbol,
+			self.expiryDate)
+		glog.Error(msg)
+		return 0, errors.New(msg)
+	}
+	return futuresPrice, nil
+}
+
+// greeksInputs returns the days to expiry and the asset price of the pricing
+// model, the futures price for Black-76 and the underlying value otherwise.
+func (self *NseOc) greeksInputs() (float64, float64, error) {
+	daysToExpiry, err := self.DaysToExpiry()
+	if err != nil {
+		return 0, 0, err
+	}
+	if self.pricingModel != bs.Black76 {
+		return daysToExpiry, self.underlyingValue, nil
+	}
+	if self.futuresPrice > 0 {
+		return daysToExpiry, self.futuresPrice, nil
+	}
+	futuresPrice, err := self.SyntheticFuturesPrice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return daysToExpiry, futuresPrice, nil
+}
+
+// newModel returns the pricing model of the strike.
+func (self *NseOc) newModel(
+	strike float64,
+	daysToExpiry float64,
+	assetPrice float64) *bs.BlackSchools {
+
+	switch self.pricingModel {
+	case bs.Merton:
+		return bs.NewMerton(assetPrice, strike, self.riskFreeRate,
+			self.dividendYield, daysToExpiry, 0, 0, 0)
+	case bs.Black76:
+		return bs.NewBlack76(assetPrice, strike, self.riskFreeRate,
+			daysToExpiry, 0, 0, 0)
+	default:
+		return bs.NewBlackSchools(assetPrice, strike, self.riskFreeRate,
+			daysToExpiry, 0, 0, 0)
+	}
+}
+
+// DaysToExpiry returns the time left to the expiry, in days, when NSE took
+// the snapshot.
+func (self *NseOc) DaysToExpiry() (float64, error) {
+	now, err := self.Time()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing timestamp=%s failed with error=%s",
+			self.timestamp, err)
+		glog.Error(msg)
+		return 0, errors.New(msg)
+	}
+	expiry, err := self.ExpiryTime()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing expiry=%s failed with error=%s",
+			self.expiryDate, err)
+		glog.Error(msg)
+		return 0, errors.New(msg)
+	}
+	days := expiry.Sub(now).Minutes() / kMinutesPerDay
+	if days < 0 {
+		days = 0
+	}
+	return days, nil
+}
+
+// Greeks returns the greeks of the CE and the PE of the strike. The IV of
+// each side is solved from its LTP, and a side is nil when its IV could not
+// be solved.
+func (self *NseOc) Greeks(strike float64) (*StrikeGreeks, error) {
+	row, ok := self.rows[strike]
+	if !ok {
+		msg := fmt.Sprintf("Strike=%g not found in the option chain.", strike)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	daysToExpiry, assetPrice, err := self.greeksInputs()
+	if err != nil {
+		return nil, err
+	}
+	return self.rowGreeks(row, daysToExpiry, assetPrice), nil
+}
+
+// AllGreeks returns the greeks of every strike of the option chain.
+func (self *NseOc) AllGreeks() (map[float64]*StrikeGreeks, error) {
+	daysToExpiry, assetPrice, err := self.greeksInputs()
+	if err != nil {
+		return nil, err
+	}
+	greeks := make(map[float64]*StrikeGreeks, len(self.rows))
+	for strike, row := range self.rows {
+		greeks[strike] = self.rowGreeks(row, daysToExpiry, assetPrice)
+	}
+	return greeks, nil
+}
+
+func (self *NseOc) rowGreeks(
+	row *NseOcRowData,
+	daysToExpiry float64,
+	assetPrice float64) *StrikeGreeks {
+
+	model := self.newModel(row.StrikePrice, daysToExpiry, assetPrice)
+	return &StrikeGreeks{
+		Strike: row.StrikePrice,
+		Ce:     self.contractGreeks(model, row.Ce, bs.Ce),
+		Pe:     self.contractGreeks(model, row.Pe, bs.Pe),
+	}
+}
+
+func (self *NseOc) contractGreeks(
+	model *bs.BlackSchools,
+	row *NseOcRow,
+	optionType bs.OptionType) *bs.OptionGreeks {
+
+	if row == nil {
+		return nil
+	}
+	strike := model.StrikePrice
+	iv, err := model.ImpliedVolatility(optionType, row.Ltp())
+	if err != nil {
+		msg := fmt.Sprintf("Failed to solve IV of strike=%g %s with error=%s",
+			strike, optionType, err)
+		glog.Error(msg)
+		return nil
+	}
+
+	// The model is shared by the CE and the PE of the strike, work on a copy.
+	solved := *model
+	solved.Volatility = iv
+	greeks, err := solved.Greeks(optionType)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to compute greeks of strike=%g %s with "+
+			"error=%s", strike, optionType, err)
+		glog.Error(msg)
+		return nil
+	}
+	greeks.IV = iv
+	return &greeks
+}
+
+// GetOptionChainShortDataWithGreeks returns the short option chain of the
+// strikes like GetOptionChainShortData, with the IV of every contract solved
+// from its LTP and its greeks. Solving the IVs is costly, so callers which
+// only rank the open interest and the volume should use
+// GetOptionChainShortData.
+func (self *NseOc) GetOptionChainShortDataWithGreeks(
+	strikes []float64) (*NseShortOc, error) {
+
+	daysToExpiry, assetPrice, err := self.greeksInputs()
+	if err != nil {
+		return nil, err
+	}
+	shortOc := self.GetOptionChainShortData(strikes)
+	for _, data := range shortOc.Oc {
+		row := self.rows[data.Strike]
+		data.setGreeks(self.rowGreeks(row, daysToExpiry, assetPrice))
+	}
+	return shortOc, nil
+}
+
+func (self *OptionChainShortData) setGreeks(greeks *StrikeGreeks) {
+	if ce := greeks.Ce; ce != nil {
+		self.CeDelta = ce.Delta
+		self.CeGamma = ce.Gamma
+		self.CeTheta = ce.Theta
+		self.CeVega = ce.Vega
+		self.CeRho = ce.Rho
+		self.CeSolvedIv = ce.IV * 100
+		self.CeIvSolved = true
+	}
+	if pe := greeks.Pe; pe != nil {
+		self.PeDelta = pe.Delta
+		self.PeGamma = pe.Gamma
+		self.PeTheta = pe.Theta
+		self.PeVega = pe.Vega
+		self.PeRho = pe.Rho
+		self.PeSolvedIv = pe.IV * 100
+		self.PeIvSolved = true
+	}
+}
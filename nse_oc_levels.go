@@ -0,0 +1,117 @@
+package nse
+
+import (
+	"sort"
+)
+
+// MaxPainPoint is the amount option writers pay out if the underlying settles
+// at Strike on expiry. The payouts are in points times open interest.
+type MaxPainPoint struct {
+	Strike      float64
+	CePayout    float64
+	PePayout    float64
+	TotalPayout float64
+}
+
+// MaxPain returns the strike at which the option writers pay out the least if
+// the underlying settles there on expiry, along with the payout curve over
+// every listed strike in ascending order of strike. It returns 0 and an empty
+// curve when the chain has no strikes.
+func (self *NseOc) MaxPain() (float64, []MaxPainPoint) {
+	curve := make([]MaxPainPoint, 0, len(self.strikes))
+	maxPainStrike := 0.0
+	minPayout := 0.0
+
+	for _, settlement := range self.strikes {
+		point := MaxPainPoint{
+			Strike:      settlement,
+			CePayout:    0,
+			PePayout:    0,
+			TotalPayout: 0,
+		}
+		for _, strike := range self.strikes {
+			row := self.rows[strike]
+			if row.Ce != nil && settlement > strike {
+				point.CePayout +=
+					(settlement - strike) * float64(row.Ce.OpenInterest())
+			}
+			if row.Pe != nil && settlement < strike {
+				point.PePayout +=
+					(strike - settlement) * float64(row.Pe.OpenInterest())
+			}
+		}
+		point.TotalPayout = point.CePayout + point.PePayout
+		curve = append(curve, point)
+
+		if len(curve) == 1 || point.TotalPayout < minPayout {
+			minPayout = point.TotalPayout
+			maxPainStrike = settlement
+		}
+	}
+	return maxPainStrike, curve
+}
+
+// OiLevel is the open interest, or the change in it, built up at a strike.
+type OiLevel struct {
+	Strike float64
+	Oi     int64
+}
+
+// OiLevels are the support and resistance strikes implied by the open
+// interest. Put writers defend the strikes with the highest PE open interest
+// (support) and call writers the ones with the highest CE open interest
+// (resistance). The change variants rank the strikes by the open interest
+// added during the day and only list strikes where it was added.
+type OiLevels struct {
+	Support          []OiLevel
+	Resistance       []OiLevel
+	ChangeSupport    []OiLevel
+	ChangeResistance []OiLevel
+}
+
+// SupportResistance returns the top n support and resistance strikes by open
+// interest and by change in open interest, highest first.
+func (self *NseOc) SupportResistance(n int) *OiLevels {
+	peOi := []OiLevel{}
+	ceOi := []OiLevel{}
+	peChangeOi := []OiLevel{}
+	ceChangeOi := []OiLevel{}
+
+	for _, strike := range self.strikes {
+		row := self.rows[strike]
+		if pe := row.Pe; pe != nil {
+			peOi = append(peOi, OiLevel{Strike: strike, Oi: pe.OpenInterest()})
+			if change := pe.ChangeOpenInterest(); change > 0 {
+				peChangeOi = append(peChangeOi, OiLevel{Strike: strike, Oi: change})
+			}
+		}
+		if ce := row.Ce; ce != nil {
+			ceOi = append(ceOi, OiLevel{Strike: strike, Oi: ce.OpenInterest()})
+			if change := ce.ChangeOpenInterest(); change > 0 {
+				ceChangeOi = append(ceChangeOi, OiLevel{Strike: strike, Oi: change})
+			}
+		}
+	}
+
+	return &OiLevels{
+		Support:          topOiLevels(peOi, n),
+		Resistance:       topOiLevels(ceOi, n),
+		ChangeSupport:    topOiLevels(peChangeOi, n),
+		ChangeResistance: topOiLevels(ceChangeOi, n),
+	}
+}
+
+// topOiLevels returns the n levels with the highest open interest. Levels
+// with the same open interest are ordered by strike.
+func topOiLevels(levels []OiLevel, n int) []OiLevel {
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].Oi > levels[j].Oi
+	})
+	if n < 0 {
+		n = 0
+	}
+	if n < len(levels) {
+		levels = levels[:n]
+	}
+	return levels
+}
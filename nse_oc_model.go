@@ -0,0 +1,260 @@
+package nse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// OcPayload is the option chain JSON served by NSE, decoded once into typed
+// structs.
+type OcPayload struct {
+	Records  *OcRecords  `json:"records"`
+	Filtered *OcFiltered `json:"filtered"`
+}
+
+// OcRecords holds the option chain of every expiry of the symbol.
+type OcRecords struct {
+	ExpiryDates     []string       `json:"expiryDates"`
+	Data            []OcDataRecord `json:"data"`
+	Timestamp       string         `json:"timestamp"`
+	UnderlyingValue float64        `json:"underlyingValue"`
+	StrikePrices    []float64      `json:"strikePrices"`
+}
+
+// OcFiltered holds the option chain of the nearest expiry along with the CE
+// and PE totals.
+type OcFiltered struct {
+	Data []OcDataRecord `json:"data"`
+	Ce   OcTotals       `json:"CE"`
+	Pe   OcTotals       `json:"PE"`
+}
+
+type OcTotals struct {
+	TotOI  float64 `json:"totOI"`
+	TotVol float64 `json:"totVol"`
+}
+
+// OcDataRecord is one strike of one expiry. Ce or Pe is nil when NSE does not
+// list the contract.
+type OcDataRecord struct {
+	StrikePrice float64
+	ExpiryDate  string
+	Ce          *OcContract
+	Pe          *OcContract
+}
+
+// OcContract holds every field NSE reports for a CE or PE contract.
+type OcContract struct {
+	StrikePrice           float64
+	ExpiryDate            string
+	Underlying            string
+	Identifier            string
+	OpenInterest          float64
+	ChangeinOpenInterest  float64
+	PchangeinOpenInterest float64
+	TotalTradedVolume     float64
+	ImpliedVolatility     float64
+	LastPrice             float64
+	Change                float64
+	PChange               float64
+	TotalBuyQuantity      float64
+	TotalSellQuantity     float64
+	BidQty                float64
+	BidPrice              float64
+	AskQty                float64
+	AskPrice              float64
+	UnderlyingValue       float64
+}
+
+// The wire types use pointers so that a field missing from the JSON can be
+// told apart from a field that is zero.
+
+type ocRecordsWire struct {
+	ExpiryDates     []string       `json:"expiryDates"`
+	Data            []OcDataRecord `json:"data"`
+	Timestamp       *string        `json:"timestamp"`
+	UnderlyingValue *float64       `json:"underlyingValue"`
+	StrikePrices    []float64      `json:"strikePrices"`
+}
+
+type ocDataRecordWire struct {
+	StrikePrice *float64    `json:"strikePrice"`
+	ExpiryDate  *string     `json:"expiryDate"`
+	Ce          *OcContract `json:"CE"`
+	Pe          *OcContract `json:"PE"`
+}
+
+type ocContractWire struct {
+	StrikePrice           *float64 `json:"strikePrice"`
+	ExpiryDate            *string  `json:"expiryDate"`
+	Underlying            *string  `json:"underlying"`
+	Identifier            *string  `json:"identifier"`
+	OpenInterest          *float64 `json:"openInterest"`
+	ChangeinOpenInterest  *float64 `json:"changeinOpenInterest"`
+	PchangeinOpenInterest *float64 `json:"pchangeinOpenInterest"`
+	TotalTradedVolume     *float64 `json:"totalTradedVolume"`
+	ImpliedVolatility     *float64 `json:"impliedVolatility"`
+	LastPrice             *float64 `json:"lastPrice"`
+	Change                *float64 `json:"change"`
+	PChange               *float64 `json:"pChange"`
+	TotalBuyQuantity      *float64 `json:"totalBuyQuantity"`
+	TotalSellQuantity     *float64 `json:"totalSellQuantity"`
+	BidQty                *float64 `json:"bidQty"`
+	BidPrice              *float64 `json:"bidprice"`
+	AskQty                *float64 `json:"askQty"`
+	AskPrice              *float64 `json:"askPrice"`
+	UnderlyingValue       *float64 `json:"underlyingValue"`
+}
+
+// fieldChecker collects the names of the required fields missing from a JSON
+// object while copying the present ones out of the wire type.
+type fieldChecker struct {
+	missing []string
+}
+
+func (self *fieldChecker) float(value *float64, field string) float64 {
+	if value == nil {
+		self.missing = append(self.missing, field)
+		return 0
+	}
+	return *value
+}
+
+func (self *fieldChecker) str(value *string, field string) string {
+	if value == nil {
+		self.missing = append(self.missing, field)
+		return ""
+	}
+	return *value
+}
+
+func (self *fieldChecker) err(object string) error {
+	if len(self.missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Parsing OC failed. %s is missing fields %s",
+		object, strings.Join(self.missing, ", "))
+}
+
+func (self *OcRecords) UnmarshalJSON(data []byte) error {
+	wire := ocRecordsWire{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	checker := fieldChecker{}
+	if wire.ExpiryDates == nil {
+		checker.missing = append(checker.missing, kOcRecordsExpiryDates)
+	}
+	if wire.Data == nil {
+		checker.missing = append(checker.missing, kOcFilteredData)
+	}
+	*self = OcRecords{
+		ExpiryDates:     wire.ExpiryDates,
+		Data:            wire.Data,
+		Timestamp:       checker.str(wire.Timestamp, kOcRecordsTimestamp),
+		UnderlyingValue: checker.float(wire.UnderlyingValue, kOcRecordsUnderlyingValue),
+		StrikePrices:    wire.StrikePrices,
+	}
+	return checker.err(kOcRecords)
+}
+
+func (self *OcDataRecord) UnmarshalJSON(data []byte) error {
+	wire := ocDataRecordWire{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	checker := fieldChecker{}
+	*self = OcDataRecord{
+		StrikePrice: checker.float(wire.StrikePrice, kOcRecordsDataStrikePrice),
+		ExpiryDate:  checker.str(wire.ExpiryDate, kOcRecordsDataExpiryDate),
+		Ce:          wire.Ce,
+		Pe:          wire.Pe,
+	}
+	return checker.err("Data record")
+}
+
+func (self *OcDataRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ocDataRecordWire{
+		StrikePrice: &self.StrikePrice,
+		ExpiryDate:  &self.ExpiryDate,
+		Ce:          self.Ce,
+		Pe:          self.Pe,
+	})
+}
+
+func (self *OcContract) UnmarshalJSON(data []byte) error {
+	wire := ocContractWire{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	checker := fieldChecker{}
+	*self = OcContract{
+		StrikePrice:           checker.float(wire.StrikePrice, "strikePrice"),
+		ExpiryDate:            checker.str(wire.ExpiryDate, "expiryDate"),
+		Underlying:            checker.str(wire.Underlying, "underlying"),
+		Identifier:            checker.str(wire.Identifier, "identifier"),
+		OpenInterest:          checker.float(wire.OpenInterest, "openInterest"),
+		ChangeinOpenInterest:  checker.float(wire.ChangeinOpenInterest, "changeinOpenInterest"),
+		PchangeinOpenInterest: checker.float(wire.PchangeinOpenInterest, "pchangeinOpenInterest"),
+		TotalTradedVolume:     checker.float(wire.TotalTradedVolume, "totalTradedVolume"),
+		ImpliedVolatility:     checker.float(wire.ImpliedVolatility, "impliedVolatility"),
+		LastPrice:             checker.float(wire.LastPrice, "lastPrice"),
+		Change:                checker.float(wire.Change, "change"),
+		PChange:               checker.float(wire.PChange, "pChange"),
+		TotalBuyQuantity:      checker.float(wire.TotalBuyQuantity, "totalBuyQuantity"),
+		TotalSellQuantity:     checker.float(wire.TotalSellQuantity, "totalSellQuantity"),
+		BidQty:                checker.float(wire.BidQty, "bidQty"),
+		BidPrice:              checker.float(wire.BidPrice, "bidprice"),
+		AskQty:                checker.float(wire.AskQty, "askQty"),
+		AskPrice:              checker.float(wire.AskPrice, "askPrice"),
+		UnderlyingValue:       checker.float(wire.UnderlyingValue, "underlyingValue"),
+	}
+	name := fmt.Sprintf("Contract %s", self.Identifier)
+	if self.Identifier == "" {
+		name = fmt.Sprintf("Contract with strike %.2f", self.StrikePrice)
+	}
+	return checker.err(name)
+}
+
+func (self *OcContract) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ocContractWire{
+		StrikePrice:           &self.StrikePrice,
+		ExpiryDate:            &self.ExpiryDate,
+		Underlying:            &self.Underlying,
+		Identifier:            &self.Identifier,
+		OpenInterest:          &self.OpenInterest,
+		ChangeinOpenInterest:  &self.ChangeinOpenInterest,
+		PchangeinOpenInterest: &self.PchangeinOpenInterest,
+		TotalTradedVolume:     &self.TotalTradedVolume,
+		ImpliedVolatility:     &self.ImpliedVolatility,
+		LastPrice:             &self.LastPrice,
+		Change:                &self.Change,
+		PChange:               &self.PChange,
+		TotalBuyQuantity:      &self.TotalBuyQuantity,
+		TotalSellQuantity:     &self.TotalSellQuantity,
+		BidQty:                &self.BidQty,
+		BidPrice:              &self.BidPrice,
+		AskQty:                &self.AskQty,
+		AskPrice:              &self.AskPrice,
+		UnderlyingValue:       &self.UnderlyingValue,
+	})
+}
+
+// ParseOcPayload decodes the option chain JSON served by NSE.
+func ParseOcPayload(data []byte) (*OcPayload, error) {
+	payload := &OcPayload{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		msg := fmt.Sprintf("Parsing OC response failed with error=%s.", err)
+		glog.Error(msg)
+		return nil, newParseError(msg)
+	}
+	if payload.Records == nil {
+		msg := fmt.Sprintf("Parsing OC failed. Field %s not found.", kOcRecords)
+		glog.Error(msg)
+		return nil, newParseError(msg)
+	}
+	return payload, nil
+}
@@ -0,0 +1,164 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/glog"
+)
+
+// ExpectedMove is the move of the underlying to expiry implied by the option
+// chain, in points.
+type ExpectedMove struct {
+	AtmStrike       float64
+	UnderlyingValue float64
+
+	// The ATM straddle price is the expected absolute move of the underlying
+	// to expiry.
+	StraddlePrice float64
+	StraddleLower float64
+	StraddleUpper float64
+
+	// AtmIv, a fraction, is the average of the IVs of the ATM CE and PE. The
+	// IV move is one standard deviation, S * IV * sqrt(T).
+	AtmIv   float64
+	IvMove  float64
+	IvLower float64
+	IvUpper float64
+}
+
+// ExpectedMove returns the move of the underlying to expiry implied by the
+// ATM straddle and by the ATM IV.
+func (self *NseOc) ExpectedMove() (*ExpectedMove, error) {
+	atmStrike := self.AtmStrike()
+	row := self.rows[atmStrike]
+	if row == nil || row.Ce == nil || row.Pe == nil {
+		msg := fmt.Sprintf("ATM strike=%g of %s expiry=%s does not have both "+
+			"CE and PE.", atmStrike, self.symbol, self.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	greeks, err := self.Greeks(atmStrike)
+	if err != nil {
+		return nil, err
+	}
+	ivSum := 0.0
+	ivCount := 0
+	if greeks.Ce != nil {
+		ivSum += greeks.Ce.IV
+		ivCount += 1
+	}
+	if greeks.Pe != nil {
+		ivSum += greeks.Pe.IV
+		ivCount += 1
+	}
+	if ivCount == 0 {
+		msg := fmt.Sprintf("IV of ATM strike=%g of %s expiry=%s is not known.",
+			atmStrike, self.symbol, self.expiryDate)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	daysToExpiry, err := self.DaysToExpiry()
+	if err != nil {
+		return nil, err
+	}
+
+	straddlePrice := row.Ce.Ltp() + row.Pe.Ltp()
+	atmIv := ivSum / float64(ivCount)
+	ivMove := self.underlyingValue * atmIv * math.Sqrt(daysToExpiry/365)
+	return &ExpectedMove{
+		AtmStrike:       atmStrike,
+		UnderlyingValue: self.underlyingValue,
+		StraddlePrice:   straddlePrice,
+		StraddleLower:   self.underlyingValue - straddlePrice,
+		StraddleUpper:   self.underlyingValue + straddlePrice,
+		AtmIv:           atmIv,
+		IvMove:          ivMove,
+		IvLower:         self.underlyingValue - ivMove,
+		IvUpper:         self.underlyingValue + ivMove,
+	}, nil
+}
+
+// ProbabilityAbove returns the market-implied probability, N(d2), of the
+// underlying expiring above the strike. The IV of the strike is taken from
+// the smile of the option chain.
+func (self *NseOc) ProbabilityAbove(strike float64) (float64, error) {
+	smile, err := self.Smile()
+	if err != nil {
+		return 0, err
+	}
+	return smile.ProbabilityAbove(strike), nil
+}
+
+// ProbabilityBelow returns the market-implied probability of the underlying
+// expiring below the strike.
+func (self *NseOc) ProbabilityBelow(strike float64) (float64, error) {
+	above, err := self.ProbabilityAbove(strike)
+	if err != nil {
+		return 0, err
+	}
+	return 1 - above, nil
+}
+
+// ProbabilityOfProfit returns the market-implied probability of the strategy
+// making a profit at the expiry of the option chain. The payoff only changes
+// sign at the breakevens, so the probability is the sum of the probabilities
+// of the underlying expiring in each range between them where the payoff is
+// positive. Every leg must expire with the option chain, so calendar spreads
+// are rejected.
+func (self *NseOc) ProbabilityOfProfit(strategy *Strategy) (float64, error) {
+	if strategy.Symbol() != self.symbol {
+		msg := fmt.Sprintf("Strategy on %s cannot be measured against the "+
+			"option chain of %s.", strategy.Symbol(), self.symbol)
+		glog.Error(msg)
+		return 0, errors.New(msg)
+	}
+	expiry, err := self.ExpiryTime()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing expiry=%s failed with error=%s",
+			self.expiryDate, err)
+		glog.Error(msg)
+		return 0, errors.New(msg)
+	}
+	for _, leg := range strategy.legs {
+		if !leg.Expiry.Equal(expiry) {
+			msg := fmt.Sprintf("Leg strike=%g %s of %s expires at %s, not "+
+				"with the option chain expiry=%s.", leg.Strike, leg.OptionType,
+				strategy.Name(), leg.Expiry, self.expiryDate)
+			glog.Error(msg)
+			return 0, errors.New(msg)
+		}
+	}
+
+	smile, err := self.Smile()
+	if err != nil {
+		return 0, err
+	}
+
+	// bounds of the ranges, 0 and +Inf included
+	bounds := append([]float64{0}, strategy.Breakevens()...)
+	bounds = append(bounds, math.Inf(1))
+
+	probability := 0.0
+	for ii := 1; ii < len(bounds); ii += 1 {
+		low, high := bounds[ii-1], bounds[ii]
+		if high <= low {
+			continue
+		}
+		inside := (low + high) / 2
+		if math.IsInf(high, 1) {
+			inside = 2*low + 1
+		}
+		if strategy.PayoffAtExpiry(inside) <= 0 {
+			continue
+		}
+		aboveHigh := 0.0
+		if !math.IsInf(high, 1) {
+			aboveHigh = smile.ProbabilityAbove(high)
+		}
+		probability += smile.ProbabilityAbove(low) - aboveHigh
+	}
+	return probability, nil
+}
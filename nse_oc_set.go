@@ -0,0 +1,185 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// NseOcSet holds the option chain of every expiry of a symbol taken from a
+// single NSE response, so all the expiries share one timestamp.
+type NseOcSet struct {
+	symbol          string
+	timestamp       string
+	underlyingValue float64
+
+	// expiry dates in ascending order of expiry
+	expiries    []string
+	expiryTimes map[string]time.Time
+	ocs         map[string]*NseOc
+}
+
+func NewNseOcSet(
+	symbol string,
+	timestamp string,
+	underlyingValue float64) *NseOcSet {
+
+	return &NseOcSet{
+		symbol:          symbol,
+		timestamp:       timestamp,
+		underlyingValue: underlyingValue,
+		expiries:        []string{},
+		expiryTimes:     map[string]time.Time{},
+		ocs:             map[string]*NseOc{},
+	}
+}
+
+// Add adds the option chain of an expiry to the set, replacing the option
+// chain of the same expiry if present.
+func (self *NseOcSet) Add(oc *NseOc) error {
+	expiryTime, err := oc.ExpiryTime()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing expiry=%s failed with error=%s",
+			oc.ExpiryDate(), err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+
+	expiryDate := oc.ExpiryDate()
+	if _, ok := self.ocs[expiryDate]; !ok {
+		self.expiries = append(self.expiries, expiryDate)
+	}
+	self.expiryTimes[expiryDate] = expiryTime
+	self.ocs[expiryDate] = oc
+
+	sort.SliceStable(self.expiries, func(i, j int) bool {
+		return self.expiryTimes[self.expiries[i]].Before(
+			self.expiryTimes[self.expiries[j]])
+	})
+	return nil
+}
+
+func (self *NseOcSet) Symbol() string {
+	return self.symbol
+}
+
+func (self *NseOcSet) Timestamp() string {
+	return self.timestamp
+}
+
+// Time returns the time at which NSE took the option chain snapshot.
+func (self *NseOcSet) Time() (time.Time, error) {
+	return ParseOcTimestamp(self.timestamp)
+}
+
+func (self *NseOcSet) UnderlyingValue() float64 {
+	return self.underlyingValue
+}
+
+// Expiries returns the expiry dates in ascending order of expiry.
+func (self *NseOcSet) Expiries() []string {
+	expiries := make([]string, len(self.expiries))
+	copy(expiries, self.expiries)
+	return expiries
+}
+
+// ExpiryTime returns the market close of the expiry day in IST.
+func (self *NseOcSet) ExpiryTime(expiryDate string) (time.Time, bool) {
+	expiryTime, ok := self.expiryTimes[expiryDate]
+	return expiryTime, ok
+}
+
+// Get returns the option chain of the expiry, or nil if the set does not
+// have it.
+func (self *NseOcSet) Get(expiryDate string) *NseOc {
+	return self.ocs[expiryDate]
+}
+
+// All returns the option chains in ascending order of expiry.
+func (self *NseOcSet) All() []*NseOc {
+	ocs := make([]*NseOc, 0, len(self.expiries))
+	for _, expiryDate := range self.expiries {
+		ocs = append(ocs, self.ocs[expiryDate])
+	}
+	return ocs
+}
+
+// activeExpiries returns the expiries which had not expired when the
+// snapshot was taken.
+func (self *NseOcSet) activeExpiries() []string {
+	now, err := self.Time()
+	if err != nil {
+		return self.expiries
+	}
+	active := []string{}
+	for _, expiryDate := range self.expiries {
+		if self.expiryTimes[expiryDate].Before(now) {
+			continue
+		}
+		active = append(active, expiryDate)
+	}
+	return active
+}
+
+// NearestWeekly returns the option chain of the nearest expiry, or nil if
+// there is none.
+func (self *NseOcSet) NearestWeekly() *NseOc {
+	active := self.activeExpiries()
+	if len(active) < 1 {
+		return nil
+	}
+	return self.ocs[active[0]]
+}
+
+// NextWeekly returns the option chain of the expiry after the nearest one,
+// or nil if there is none.
+func (self *NseOcSet) NextWeekly() *NseOc {
+	active := self.activeExpiries()
+	if len(active) < 2 {
+		return nil
+	}
+	return self.ocs[active[1]]
+}
+
+// MonthlyExpiries returns the monthly expiry dates in ascending order. The
+// monthly expiry is the last listed expiry of a calendar month.
+func (self *NseOcSet) MonthlyExpiries() []string {
+	active := self.activeExpiries()
+	monthly := []string{}
+	for ii, expiryDate := range active {
+		if ii+1 < len(active) &&
+			sameMonth(self.expiryTimes[expiryDate],
+				self.expiryTimes[active[ii+1]]) {
+			continue
+		}
+		monthly = append(monthly, expiryDate)
+	}
+	return monthly
+}
+
+// Monthly returns the option chain of the nearest monthly expiry, or nil if
+// there is none.
+func (self *NseOcSet) Monthly() *NseOc {
+	monthly := self.MonthlyExpiries()
+	if len(monthly) < 1 {
+		return nil
+	}
+	return self.ocs[monthly[0]]
+}
+
+// NextMonthly returns the option chain of the monthly expiry after the
+// nearest one, or nil if there is none.
+func (self *NseOcSet) NextMonthly() *NseOc {
+	monthly := self.MonthlyExpiries()
+	if len(monthly) < 2 {
+		return nil
+	}
+	return self.ocs[monthly[1]]
+}
+
+func sameMonth(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
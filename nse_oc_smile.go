@@ -0,0 +1,131 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/joshi-prasad/nse/bs"
+)
+
+// Smile fits the IV smile of the expiry. The IVs are solved from the LTPs
+// with Black-76 off the futures price, the one set with SetFuturesPrice or
+// else the synthetic futures price, so they match the IVs quoted by brokers.
+// Only the OTM side of each strike is used, the PE below the futures price
+// and the CE at or above it, as the ITM side trades thinly.
+func (self *NseOc) Smile() (*bs.Smile, error) {
+	daysToExpiry, err := self.DaysToExpiry()
+	if err != nil {
+		return nil, err
+	}
+	futuresPrice := self.futuresPrice
+	if futuresPrice <= 0 {
+		futuresPrice, err = self.SyntheticFuturesPrice()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	points := []bs.SmilePoint{}
+	for _, strike := range self.strikes {
+		row := self.rows[strike]
+		contract, optionType := row.Ce, bs.Ce
+		if strike < futuresPrice {
+			contract, optionType = row.Pe, bs.Pe
+		}
+		if contract == nil || contract.Ltp() <= 0 {
+			continue
+		}
+		model := bs.NewBlack76(futuresPrice, strike, self.riskFreeRate,
+			daysToExpiry, 0, 0, 0)
+		iv, err := model.ImpliedVolatility(optionType, contract.Ltp())
+		if err != nil {
+			msg := fmt.Sprintf("Skipping strike=%g %s in the smile of %s "+
+				"expiry=%s. %s", strike, optionType, self.symbol, self.expiryDate,
+				err)
+			glog.Info(msg)
+			continue
+		}
+		points = append(points, bs.SmilePoint{Strike: strike, IV: iv})
+	}
+
+	smile, err := bs.NewSmile(futuresPrice, daysToExpiry, points)
+	if err != nil {
+		msg := fmt.Sprintf("Fitting the smile of %s expiry=%s failed with "+
+			"error=%s", self.symbol, self.expiryDate, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	return smile, nil
+}
+
+// IvSurface is the IV surface of every expiry of an NseOcSet.
+type IvSurface struct {
+	// time at which NSE took the option chain snapshot
+	time    time.Time
+	surface *bs.Surface
+	smiles  map[string]*bs.Smile
+}
+
+// Surface fits the smile of every expiry and builds the IV surface from
+// them. Expiries whose smile cannot be fitted, such as the ones expiring on
+// the day of the snapshot, are left out.
+func (self *NseOcSet) Surface() (*IvSurface, error) {
+	now, err := self.Time()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing timestamp=%s failed with error=%s",
+			self.timestamp, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	smiles := map[string]*bs.Smile{}
+	fitted := []*bs.Smile{}
+	for _, expiryDate := range self.expiries {
+		smile, err := self.ocs[expiryDate].Smile()
+		if err != nil {
+			continue
+		}
+		smiles[expiryDate] = smile
+		fitted = append(fitted, smile)
+	}
+
+	surface, err := bs.NewSurface(fitted)
+	if err != nil {
+		msg := fmt.Sprintf("Building the IV surface of %s failed with "+
+			"error=%s", self.symbol, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	return &IvSurface{
+		time:    now,
+		surface: surface,
+		smiles:  smiles,
+	}, nil
+}
+
+func (self *IvSurface) Surface() *bs.Surface {
+	return self.surface
+}
+
+// Smile returns the smile of the expiry, or nil if it was not fitted.
+func (self *IvSurface) Smile(expiryDate string) *bs.Smile {
+	return self.smiles[expiryDate]
+}
+
+func (self *IvSurface) daysTo(expiry time.Time) float64 {
+	return expiry.Sub(self.time).Minutes() / kMinutesPerDay
+}
+
+// IV returns the implied volatility, a fraction, of the strike for an option
+// expiring at expiry.
+func (self *IvSurface) IV(strike float64, expiry time.Time) float64 {
+	return self.surface.IV(strike, self.daysTo(expiry))
+}
+
+// AtmIV returns the implied volatility, a fraction, at the forward price for
+// an option expiring at expiry.
+func (self *IvSurface) AtmIV(expiry time.Time) float64 {
+	return self.surface.AtmIV(self.daysTo(expiry))
+}
@@ -0,0 +1,204 @@
+package nse_test
+
+import (
+	"testing"
+
+	"github.com/joshi-prasad/nse"
+	"github.com/joshi-prasad/nse/bs"
+	"github.com/joshi-prasad/nse/nsetest"
+)
+
+var kPricedExpiries = []string{"01-Jun-2023", "08-Jun-2023"}
+
+// newPricedChain returns a NIFTY chain of two expiries whose contracts are
+// priced with Black-Scholes at an IV of 12%.
+func newPricedChain(t *testing.T) *nsetest.OptionChain {
+	chain := &nsetest.OptionChain{
+		Symbol:          "NIFTY",
+		Equity:          false,
+		Timestamp:       "25-May-2023 10:00:00",
+		UnderlyingValue: 18520,
+		Strikes:         []nsetest.Strike{},
+	}
+	now, err := nse.ParseOcTimestamp(chain.Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expiryDate := range kPricedExpiries {
+		expiry, err := nse.ParseExpiryDate(expiryDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		days := expiry.Sub(now).Hours() / 24
+		for strike := 18000.0; strike <= 19000; strike += 50 {
+			model := bs.NewBlackSchools(chain.UnderlyingValue, strike, 7, days,
+				12, 0, 0)
+			ce, _ := model.Price(bs.Ce, model.Volatility)
+			pe, _ := model.Price(bs.Pe, model.Volatility)
+			chain.Strikes = append(chain.Strikes, nsetest.Strike{
+				ExpiryDate:  expiryDate,
+				StrikePrice: strike,
+				Ce: &nsetest.Contract{
+					OpenInterest:      1000,
+					LastPrice:         ce,
+					ImpliedVolatility: 12,
+				},
+				Pe: &nsetest.Contract{
+					OpenInterest:      1000,
+					LastPrice:         pe,
+					ImpliedVolatility: 12,
+				},
+			})
+		}
+	}
+	return chain
+}
+
+// The test chain quotes the CE and the PE of every strike at 10 and 12, below
+// the intrinsic value of the ITM contracts, so their IV cannot be solved.
+func TestGreeksLeaveUnsolvedSidesOut(t *testing.T) {
+	_, client := newTestServer(t)
+	oc := fetchTestChain(t, client)
+
+	greeks, err := oc.Greeks(18300)
+	if err != nil {
+		t.Fatalf("Greeks failed: %v", err)
+	}
+	if greeks.Ce != nil {
+		t.Errorf("got CE greeks with IV %v, want nil for an unsolvable LTP",
+			greeks.Ce.IV)
+	}
+	if greeks.Pe == nil || greeks.Pe.IV <= 0 {
+		t.Fatalf("got PE greeks %+v, want the IV solved from the LTP",
+			greeks.Pe)
+	}
+
+	shortOc, err := oc.GetOptionChainShortDataWithGreeks([]float64{18300})
+	if err != nil {
+		t.Fatalf("GetOptionChainShortDataWithGreeks failed: %v", err)
+	}
+	row := shortOc.Oc[0]
+	if row.CeIvSolved || row.CeSolvedIv != 0 || row.CeDelta != 0 {
+		t.Errorf("got CE solved=%v IV %v delta %v, want nothing solved",
+			row.CeIvSolved, row.CeSolvedIv, row.CeDelta)
+	}
+	if !row.PeIvSolved || row.PeSolvedIv != greeks.Pe.IV*100 {
+		t.Errorf("got PE solved=%v IV %v, want IV %v", row.PeIvSolved,
+			row.PeSolvedIv, greeks.Pe.IV*100)
+	}
+}
+
+func TestShortDataSolvesGreeksOnlyWhenAsked(t *testing.T) {
+	_, client := newTestServer(t)
+	oc := fetchTestChain(t, client)
+
+	strikes := oc.Strikes()
+	for _, row := range oc.GetOptionChainShortData(strikes).Oc {
+		if row.CeIvSolved || row.PeIvSolved {
+			t.Errorf("strike=%g: got greeks from GetOptionChainShortData",
+				row.Strike)
+		}
+	}
+
+	shortOc, err := oc.GetOptionChainShortDataWithGreeks(strikes)
+	if err != nil {
+		t.Fatalf("GetOptionChainShortDataWithGreeks failed: %v", err)
+	}
+	// the OTM CEs are above the underlying value of 18520
+	row := shortOc.Oc[len(shortOc.Oc)-1]
+	if !row.CeIvSolved || row.CeDelta <= 0 {
+		t.Errorf("strike=%g: got CE solved=%v delta %v, want the greeks",
+			row.Strike, row.CeIvSolved, row.CeDelta)
+	}
+}
+
+func TestProbabilityOfProfitRejectsOtherExpiries(t *testing.T) {
+	server, client := newTestServer(t)
+	server.SetOptionChain("NIFTY", newPricedChain(t).JSON())
+	set, err := client.FetchOcSet("NIFTY")
+	if err != nil {
+		t.Fatalf("FetchOcSet failed: %v", err)
+	}
+	near := set.Get(kPricedExpiries[0])
+	far := set.Get(kPricedExpiries[1])
+
+	straddle, err := nse.NewStraddle(near, 18500, nse.Sell, 1, 50)
+	if err != nil {
+		t.Fatalf("NewStraddle failed: %v", err)
+	}
+	probability, err := near.ProbabilityOfProfit(straddle)
+	if err != nil || probability <= 0 || probability >= 1 {
+		t.Fatalf("got probability %v with error %v, want one in (0, 1)",
+			probability, err)
+	}
+	if _, err := far.ProbabilityOfProfit(straddle); err == nil {
+		t.Errorf("got no error for a straddle of another expiry")
+	}
+
+	calendar := nse.NewStrategy("Calendar", "NIFTY", 50)
+	if err := calendar.AddLegFromOc(near, nse.OptionTypeCe, 18500, nse.Sell,
+		1); err != nil {
+		t.Fatalf("AddLegFromOc failed: %v", err)
+	}
+	if err := calendar.AddLegFromOc(far, nse.OptionTypeCe, 18500, nse.Buy,
+		1); err != nil {
+		t.Fatalf("AddLegFromOc failed: %v", err)
+	}
+	if _, err := near.ProbabilityOfProfit(calendar); err == nil {
+		t.Errorf("got no error for a calendar spread")
+	}
+}
+
+func TestShortDiffLeavesOutContractsMissingFromOneSnapshot(t *testing.T) {
+	server, client := newTestServer(t)
+	chain := newTestChain()
+	// the 18400 CE is listed only in the current snapshot
+	chain.Strikes[2].Ce = nil
+	server.SetOptionChain("NIFTY", chain.JSON())
+	prev := fetchTestChain(t, client)
+
+	chain = newTestChain()
+	// the 18500 PE is listed only in the previous snapshot
+	chain.Strikes[4].Pe = nil
+	chain.Strikes[4].Ce.OpenInterest += 500
+	server.SetOptionChain("NIFTY", chain.JSON())
+	cur := fetchTestChain(t, client)
+
+	strikes := []float64{18400, 18500}
+	prevShort := prev.GetOptionChainShortData(strikes)
+	curShort := cur.GetOptionChainShortData(strikes)
+	// presence does not depend on the identifier
+	for _, row := range curShort.Oc {
+		row.CeIdentifier = ""
+		row.PeIdentifier = ""
+	}
+
+	shortDiff, err := curShort.Diff(prevShort)
+	if err != nil {
+		t.Fatalf("NseShortOc.Diff failed: %v", err)
+	}
+	ocDiff, err := cur.NseOcForStrikes(strikes).Diff(
+		prev.NseOcForStrikes(strikes))
+	if err != nil {
+		t.Fatalf("NseOc.Diff failed: %v", err)
+	}
+
+	for _, diff := range []*nse.OcDiff{shortDiff, ocDiff} {
+		if len(diff.Strikes) != 2 {
+			t.Fatalf("got %d strikes, want 2", len(diff.Strikes))
+		}
+		at18400, at18500 := diff.Strikes[0], diff.Strikes[1]
+		if at18400.Ce != nil || at18400.Pe == nil {
+			t.Errorf("18400: got CE %+v PE %+v, want only the PE",
+				at18400.Ce, at18400.Pe)
+		}
+		if at18500.Pe != nil || at18500.Ce == nil {
+			t.Fatalf("18500: got CE %+v PE %+v, want only the CE",
+				at18500.Ce, at18500.Pe)
+		}
+		if at18500.Ce.OpenInterest != 500 {
+			t.Errorf("18500: got CE OI change %d, want 500",
+				at18500.Ce.OpenInterest)
+		}
+	}
+}
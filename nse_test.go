@@ -0,0 +1,231 @@
+package nse_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joshi-prasad/nse"
+	"github.com/joshi-prasad/nse/nsetest"
+)
+
+const kTestExpiry = "01-Jun-2023"
+
+func newTestChain() *nsetest.OptionChain {
+	chain := &nsetest.OptionChain{
+		Symbol:          "NIFTY",
+		Equity:          false,
+		Timestamp:       "01-Jun-2023 10:00:00",
+		UnderlyingValue: 18520,
+		Strikes:         []nsetest.Strike{},
+	}
+	for strike := 18300.0; strike <= 18700; strike += 50 {
+		chain.Strikes = append(chain.Strikes, nsetest.Strike{
+			ExpiryDate:  kTestExpiry,
+			StrikePrice: strike,
+			Ce: &nsetest.Contract{
+				OpenInterest:      1000,
+				LastPrice:         10,
+				ImpliedVolatility: 12,
+			},
+			Pe: &nsetest.Contract{
+				OpenInterest:      2000,
+				LastPrice:         12,
+				ImpliedVolatility: 13,
+			},
+		})
+	}
+	return chain
+}
+
+// newTestServer serves the test chain as NIFTY and returns a client which
+// retries right away and does not share responses between calls.
+func newTestServer(t *testing.T) (*nsetest.Server, *nse.NSE) {
+	server := nsetest.NewServer()
+	t.Cleanup(server.Close)
+	server.SetOptionChain("NIFTY", newTestChain().JSON())
+
+	policy := nse.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = time.Millisecond
+	client := server.NSE(
+		nse.WithRetryPolicy(policy),
+		nse.WithCoalesceWindow(0))
+	return server, client
+}
+
+func fetchTestChain(t *testing.T, client *nse.NSE) *nse.NseOc {
+	oc, err := client.FetchOptionChain("NIFTY", kTestExpiry)
+	if err != nil {
+		t.Fatalf("FetchOptionChain failed: %v", err)
+	}
+	return oc
+}
+
+func TestFetchUrlGzipAndPlain(t *testing.T) {
+	server, client := newTestServer(t)
+	for _, gzip := range []bool{true, false} {
+		server.SetGzip(gzip)
+		oc := fetchTestChain(t, client)
+		if got := len(oc.Strikes()); got != 9 {
+			t.Errorf("gzip=%v: got %d strikes, want 9", gzip, got)
+		}
+		if got := oc.TotalCeOi(); got != 9000 {
+			t.Errorf("gzip=%v: got TotalCeOi %d, want 9000", gzip, got)
+		}
+		if got := oc.UnderlyingValue(); got != 18520 {
+			t.Errorf("gzip=%v: got underlying %v, want 18520", gzip, got)
+		}
+	}
+}
+
+func TestFetchUrlRefreshesCookieOn401(t *testing.T) {
+	server, client := newTestServer(t)
+	fetchTestChain(t, client)
+	handshakes := server.Hits(nsetest.OptionChainPath)
+	requests := server.Hits(nsetest.OptionChainIndicesPath)
+
+	server.ExpireCookies()
+	fetchTestChain(t, client)
+	if got := server.Hits(nsetest.OptionChainPath) - handshakes; got != 1 {
+		t.Errorf("got %d cookie handshakes after the 401, want 1", got)
+	}
+	if got := server.Hits(nsetest.OptionChainIndicesPath) - requests; got != 2 {
+		t.Errorf("got %d requests, want the 401 and the retry", got)
+	}
+}
+
+func TestFetchUrlFailsWhenCookieRefreshFails(t *testing.T) {
+	server, client := newTestServer(t)
+	server.FailNext(nsetest.OptionChainPath, http.StatusServiceUnavailable)
+
+	_, err := client.FetchOptionChain("NIFTY", kTestExpiry)
+	statusErr := &nse.HTTPStatusError{}
+	if !errors.As(err, &statusErr) ||
+		statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want an HTTPStatusError with 503", err)
+	}
+	// the request is not sent without a cookie
+	if got := server.Hits(nsetest.OptionChainIndicesPath); got != 0 {
+		t.Errorf("got %d requests, want 0", got)
+	}
+
+	fetchTestChain(t, client)
+	server.ExpireCookies()
+	server.FailNext(nsetest.OptionChainPath, http.StatusForbidden)
+	_, err = client.FetchOptionChain("NIFTY", kTestExpiry)
+	if !errors.Is(err, nse.ErrForbidden) {
+		t.Fatalf("got error %v, want ErrForbidden", err)
+	}
+}
+
+func TestFetchUrlGivesUpOnRepeated401(t *testing.T) {
+	server, client := newTestServer(t)
+	fetchTestChain(t, client)
+	handshakes := server.Hits(nsetest.OptionChainPath)
+
+	server.FailNext(nsetest.OptionChainIndicesPath,
+		http.StatusUnauthorized, http.StatusUnauthorized,
+		http.StatusUnauthorized)
+	_, err := client.FetchOptionChain("NIFTY", kTestExpiry)
+	if !errors.Is(err, nse.ErrUnauthorized) {
+		t.Fatalf("got error %v, want ErrUnauthorized", err)
+	}
+	// the default policy refreshes the cookie twice
+	if got := server.Hits(nsetest.OptionChainPath) - handshakes; got != 2 {
+		t.Errorf("got %d cookie handshakes, want 2", got)
+	}
+}
+
+func TestFetchUrlFailsOn403(t *testing.T) {
+	server, client := newTestServer(t)
+	server.FailNext(nsetest.OptionChainIndicesPath, http.StatusForbidden)
+
+	_, err := client.FetchOptionChain("NIFTY", kTestExpiry)
+	if !errors.Is(err, nse.ErrForbidden) {
+		t.Fatalf("got error %v, want ErrForbidden", err)
+	}
+	statusErr := &nse.HTTPStatusError{}
+	if !errors.As(err, &statusErr) ||
+		statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("got error %v, want an HTTPStatusError with 403", err)
+	}
+	if got := server.Hits(nsetest.OptionChainIndicesPath); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+
+	// the 403 is not sticky
+	fetchTestChain(t, client)
+}
+
+func TestFetchUrlRetriesBurstOf5xx(t *testing.T) {
+	server, client := newTestServer(t)
+	server.FailNext(nsetest.OptionChainIndicesPath,
+		http.StatusServiceUnavailable, http.StatusBadGateway,
+		http.StatusInternalServerError)
+
+	fetchTestChain(t, client)
+	if got := server.Hits(nsetest.OptionChainIndicesPath); got != 4 {
+		t.Errorf("got %d requests, want 3 failures and 1 success", got)
+	}
+}
+
+func TestFetchUrlGivesUpAfterMaxAttempts(t *testing.T) {
+	server, client := newTestServer(t)
+	maxAttempts := nse.DefaultRetryPolicy().MaxAttempts
+	for ii := 0; ii < maxAttempts; ii += 1 {
+		server.FailNext(nsetest.OptionChainIndicesPath,
+			http.StatusServiceUnavailable)
+	}
+
+	_, err := client.FetchOptionChain("NIFTY", kTestExpiry)
+	statusErr := &nse.HTTPStatusError{}
+	if !errors.As(err, &statusErr) ||
+		statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want an HTTPStatusError with 503", err)
+	}
+	if got := server.Hits(nsetest.OptionChainIndicesPath); got != maxAttempts {
+		t.Errorf("got %d requests, want %d", got, maxAttempts)
+	}
+}
+
+// TestFetchConcurrently fetches from many goroutines while the cookies
+// expire, and is meant to be run with -race.
+func TestFetchConcurrently(t *testing.T) {
+	server, client := newTestServer(t)
+	server.SetOptionChain("BANKNIFTY", newTestChain().JSON())
+
+	for round := 0; round < 3; round += 1 {
+		server.ExpireCookies()
+		wg := sync.WaitGroup{}
+		for ii := 0; ii < 16; ii += 1 {
+			wg.Add(1)
+			go func(ii int) {
+				defer wg.Done()
+				symbol := "NIFTY"
+				if ii%2 == 0 {
+					symbol = "BANKNIFTY"
+				}
+				set, err := client.FetchOcSet(symbol)
+				if err != nil {
+					t.Errorf("FetchOcSet(%s) failed: %v", symbol, err)
+					return
+				}
+				if set.Get(kTestExpiry) == nil {
+					t.Errorf("FetchOcSet(%s) is missing expiry=%s", symbol,
+						kTestExpiry)
+				}
+				if ii%5 == 0 {
+					if err := client.FetchCookieContext(
+						context.Background()); err != nil {
+						t.Errorf("FetchCookie failed: %v", err)
+					}
+				}
+			}(ii)
+		}
+		wg.Wait()
+	}
+}
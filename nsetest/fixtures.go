@@ -0,0 +1,168 @@
+package nsetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Contract holds the fields NSE reports for a single CE or PE contract.
+type Contract struct {
+	OpenInterest          float64
+	ChangeinOpenInterest  float64
+	PchangeinOpenInterest float64
+	TotalTradedVolume     float64
+	ImpliedVolatility     float64
+	LastPrice             float64
+	Change                float64
+	PChange               float64
+	TotalBuyQuantity      float64
+	TotalSellQuantity     float64
+	BidQty                float64
+	BidPrice              float64
+	AskQty                float64
+	AskPrice              float64
+}
+
+// Strike is one row of the option chain. Either side may be nil.
+type Strike struct {
+	ExpiryDate  string
+	StrikePrice float64
+	Ce          *Contract
+	Pe          *Contract
+}
+
+// OptionChain builds an option chain JSON body shaped like the one served by
+// nseindia.com. Set Equity for the option chain of a stock.
+type OptionChain struct {
+	Symbol          string
+	Equity          bool
+	Timestamp       string
+	UnderlyingValue float64
+	Strikes         []Strike
+}
+
+// JSON returns the option chain encoded the way NSE encodes it. The filtered
+// section holds the nearest expiry only.
+func (self *OptionChain) JSON() []byte {
+	expiries := self.expiryDates()
+	strikeSet := map[float64]bool{}
+	data := []map[string]interface{}{}
+	filtered := []map[string]interface{}{}
+	totals := map[string]map[string]float64{
+		"CE": {"totOI": 0, "totVol": 0},
+		"PE": {"totOI": 0, "totVol": 0},
+	}
+
+	for _, strike := range self.Strikes {
+		strikeSet[strike.StrikePrice] = true
+		record := map[string]interface{}{
+			"strikePrice": strike.StrikePrice,
+			"expiryDate":  strike.ExpiryDate,
+		}
+		if strike.Ce != nil {
+			record["CE"] = self.contractJson(strike, "CE", strike.Ce)
+		}
+		if strike.Pe != nil {
+			record["PE"] = self.contractJson(strike, "PE", strike.Pe)
+		}
+		data = append(data, record)
+
+		if len(expiries) == 0 || strike.ExpiryDate != expiries[0] {
+			continue
+		}
+		filtered = append(filtered, record)
+		if strike.Ce != nil {
+			totals["CE"]["totOI"] += strike.Ce.OpenInterest
+			totals["CE"]["totVol"] += strike.Ce.TotalTradedVolume
+		}
+		if strike.Pe != nil {
+			totals["PE"]["totOI"] += strike.Pe.OpenInterest
+			totals["PE"]["totVol"] += strike.Pe.TotalTradedVolume
+		}
+	}
+
+	strikePrices := []float64{}
+	for strike := range strikeSet {
+		strikePrices = append(strikePrices, strike)
+	}
+	sort.Float64s(strikePrices)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"records": map[string]interface{}{
+			"expiryDates":     expiries,
+			"data":            data,
+			"timestamp":       self.Timestamp,
+			"underlyingValue": self.UnderlyingValue,
+			"strikePrices":    strikePrices,
+		},
+		"filtered": map[string]interface{}{
+			"data": filtered,
+			"CE":   totals["CE"],
+			"PE":   totals["PE"],
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return body
+}
+
+func (self *OptionChain) expiryDates() []string {
+	seen := map[string]bool{}
+	expiries := []string{}
+	for _, strike := range self.Strikes {
+		if seen[strike.ExpiryDate] {
+			continue
+		}
+		seen[strike.ExpiryDate] = true
+		expiries = append(expiries, strike.ExpiryDate)
+	}
+	sort.SliceStable(expiries, func(i, j int) bool {
+		a, errA := time.Parse("02-Jan-2006", expiries[i])
+		b, errB := time.Parse("02-Jan-2006", expiries[j])
+		if errA != nil || errB != nil {
+			return false
+		}
+		return a.Before(b)
+	})
+	return expiries
+}
+
+func (self *OptionChain) contractJson(
+	strike Strike,
+	optionType string,
+	contract *Contract) map[string]interface{} {
+
+	identifierDate := strike.ExpiryDate
+	if expiry, err := time.Parse("02-Jan-2006", strike.ExpiryDate); err == nil {
+		identifierDate = expiry.Format("02-01-2006")
+	}
+	instrument := "OPTIDX"
+	if self.Equity {
+		instrument = "OPTSTK"
+	}
+	return map[string]interface{}{
+		"strikePrice": strike.StrikePrice,
+		"expiryDate":  strike.ExpiryDate,
+		"underlying":  self.Symbol,
+		"identifier": fmt.Sprintf("%s%s%s%s%.2f", instrument, self.Symbol,
+			identifierDate, optionType, strike.StrikePrice),
+		"openInterest":          contract.OpenInterest,
+		"changeinOpenInterest":  contract.ChangeinOpenInterest,
+		"pchangeinOpenInterest": contract.PchangeinOpenInterest,
+		"totalTradedVolume":     contract.TotalTradedVolume,
+		"impliedVolatility":     contract.ImpliedVolatility,
+		"lastPrice":             contract.LastPrice,
+		"change":                contract.Change,
+		"pChange":               contract.PChange,
+		"totalBuyQuantity":      contract.TotalBuyQuantity,
+		"totalSellQuantity":     contract.TotalSellQuantity,
+		"bidQty":                contract.BidQty,
+		"bidprice":              contract.BidPrice,
+		"askQty":                contract.AskQty,
+		"askPrice":              contract.AskPrice,
+		"underlyingValue":       self.UnderlyingValue,
+	}
+}
@@ -0,0 +1,254 @@
+// Package nsetest provides a local stand-in for the NSE web servers so that
+// the nse client can be exercised without hitting nseindia.com.
+package nsetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshi-prasad/nse"
+)
+
+const (
+	OptionChainPath         = "/option-chain"
+	OptionChainIndicesPath  = "/api/option-chain-indices"
+	OptionChainEquitiesPath = "/api/option-chain-equities"
+	ParticipantOiPathPrefix = "/content/nsccl/fao_participant_oi_"
+
+	kCookieName = "nsit"
+)
+
+// Server mimics the NSE endpoints used by the nse client:
+//
+//   - OptionChainPath hands out the session cookie,
+//   - OptionChainIndicesPath and OptionChainEquitiesPath serve the option
+//     chain of an index or a stock and answer 401 unless a valid session
+//     cookie is sent,
+//   - ParticipantOiPathPrefix + DDMMYYYY.csv serves the F&O participant data.
+//
+// Responses can be scripted per path using FailNext to exercise the retry
+// handling of the client.
+type Server struct {
+	*httptest.Server
+
+	mutex         sync.Mutex
+	gzip          bool
+	cookieSeq     int
+	validCookies  map[string]bool
+	optionChains  map[string][]byte
+	equityChains  map[string][]byte
+	participantOi map[string][]byte
+	scripts       map[string][]int
+	hits          map[string]int
+}
+
+// NewServer starts a stand-in server. Callers must Close it when done.
+func NewServer() *Server {
+	self := &Server{
+		gzip:          true,
+		validCookies:  map[string]bool{},
+		optionChains:  map[string][]byte{},
+		equityChains:  map[string][]byte{},
+		participantOi: map[string][]byte{},
+		scripts:       map[string][]int{},
+		hits:          map[string]int{},
+	}
+	self.Server = httptest.NewServer(http.HandlerFunc(self.serveHTTP))
+	return self
+}
+
+// NSE returns an nse client which talks to this server. The given options are
+// applied after the ones pointing the client at the server.
+func (self *Server) NSE(options ...nse.NseOption) *nse.NSE {
+	all := []nse.NseOption{
+		nse.WithHTTPClient(self.Client()),
+		nse.WithBaseURL(self.URL),
+		nse.WithArchivesURL(self.URL),
+	}
+	return nse.NewNSE(append(all, options...)...)
+}
+
+// SetGzip controls whether the responses are gzip encoded when the client
+// accepts it. It is enabled by default, like on nseindia.com.
+func (self *Server) SetGzip(enabled bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.gzip = enabled
+}
+
+// SetOptionChain sets the JSON body served for the option chain of symbol.
+func (self *Server) SetOptionChain(symbol string, body []byte) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.optionChains[symbol] = body
+}
+
+// SetEquityOptionChain sets the JSON body served for the option chain of the
+// stock symbol.
+func (self *Server) SetEquityOptionChain(symbol string, body []byte) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.equityChains[symbol] = body
+}
+
+// SetParticipantOi sets the CSV body served as the F&O participant data of
+// the given date.
+func (self *Server) SetParticipantOi(date time.Time, body []byte) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.participantOi[participantOiDate(date)] = body
+}
+
+// FailNext makes the next requests to path fail with the given HTTP statuses,
+// one status per request, before the path serves normally again. For example
+// FailNext(OptionChainIndicesPath, 503, 503, 503) scripts a burst of 5xx.
+// Scripting 401 does not invalidate the session cookie, use ExpireCookies for
+// that.
+func (self *Server) FailNext(path string, statuses ...int) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.scripts[path] = append(self.scripts[path], statuses...)
+}
+
+// ExpireCookies invalidates every session cookie handed out so far, forcing
+// the client through the cookie handshake again.
+func (self *Server) ExpireCookies() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.validCookies = map[string]bool{}
+}
+
+// Hits returns the number of requests received for path. For the participant
+// data the path includes the file name.
+func (self *Server) Hits(path string) int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.hits[path]
+}
+
+func (self *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+
+	self.mutex.Lock()
+	self.hits[path] += 1
+	status := 0
+	if script := self.scripts[path]; len(script) > 0 {
+		status = script[0]
+		self.scripts[path] = script[1:]
+	}
+	self.mutex.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	switch {
+	case path == OptionChainPath:
+		self.serveCookie(w)
+	case path == OptionChainIndicesPath:
+		self.serveOptionChain(w, req, self.optionChains)
+	case path == OptionChainEquitiesPath:
+		self.serveOptionChain(w, req, self.equityChains)
+	case strings.HasPrefix(path, ParticipantOiPathPrefix):
+		self.serveParticipantOi(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (self *Server) serveCookie(w http.ResponseWriter) {
+	self.mutex.Lock()
+	self.cookieSeq += 1
+	value := fmt.Sprintf("session-%d", self.cookieSeq)
+	self.validCookies[value] = true
+	self.mutex.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: kCookieName, Value: value, Path: "/"})
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte("<html><body>Option Chain</body></html>"))
+}
+
+func (self *Server) hasValidCookie(req *http.Request) bool {
+	cookie, err := req.Cookie(kCookieName)
+	if err != nil {
+		return false
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.validCookies[cookie.Value]
+}
+
+func (self *Server) serveOptionChain(
+	w http.ResponseWriter,
+	req *http.Request,
+	chains map[string][]byte) {
+
+	if !self.hasValidCookie(req) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized),
+			http.StatusUnauthorized)
+		return
+	}
+
+	symbol := req.URL.Query().Get("symbol")
+	self.mutex.Lock()
+	body, ok := chains[symbol]
+	self.mutex.Unlock()
+	if !ok {
+		// NSE answers unknown symbols with an empty JSON object.
+		body = []byte("{}")
+	}
+	self.write(w, req, "application/json", body)
+}
+
+func (self *Server) serveParticipantOi(
+	w http.ResponseWriter,
+	req *http.Request) {
+
+	name := strings.TrimPrefix(req.URL.Path, ParticipantOiPathPrefix)
+	date := strings.TrimSuffix(name, ".csv")
+
+	self.mutex.Lock()
+	body, ok := self.participantOi[date]
+	self.mutex.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	self.write(w, req, "text/csv", body)
+}
+
+func (self *Server) write(
+	w http.ResponseWriter,
+	req *http.Request,
+	contentType string,
+	body []byte) {
+
+	self.mutex.Lock()
+	useGzip := self.gzip &&
+		strings.Contains(req.Header.Get("Accept-Encoding"), "gzip")
+	self.mutex.Unlock()
+
+	w.Header().Set("Content-Type", contentType)
+	if !useGzip {
+		w.Write(body)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	writer.Write(body)
+	writer.Close()
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Write(buf.Bytes())
+}
+
+func participantOiDate(date time.Time) string {
+	return fmt.Sprintf("%02d%02d%d", date.Day(), date.Month(), date.Year())
+}
@@ -0,0 +1,349 @@
+package nse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	kDefaultPollInterval = 3 * time.Minute
+	kMinPollBackoff      = 5 * time.Minute
+	kMaxPollBackoff      = 30 * time.Minute
+)
+
+// PollTarget is a symbol the Poller fetches the option chain of.
+type PollTarget struct {
+	Symbol string
+	// true for an F&O stock, false for an index
+	Equity bool
+	// expiry dates to publish, every expiry when empty
+	Expiries []string
+	// 0 uses the interval of the poller
+	Interval time.Duration
+}
+
+// PollerOption configures the Poller created by NewPoller.
+type PollerOption func(*Poller)
+
+// WithPollInterval sets the interval of the targets which do not set their
+// own. The default is 3 minutes.
+func WithPollInterval(interval time.Duration) PollerOption {
+	return func(self *Poller) {
+		self.interval = interval
+	}
+}
+
+// WithMarketCalendar sets the calendar of the trading sessions. The default
+// calendar skips the weekends but knows no exchange holidays.
+func WithMarketCalendar(calendar *MarketCalendar) PollerOption {
+	return func(self *Poller) {
+		self.calendar = calendar
+	}
+}
+
+// WithMarketHoursOnly makes the poller fetch only while the market is open,
+// which is the default, or at any time.
+func WithMarketHoursOnly(marketHoursOnly bool) PollerOption {
+	return func(self *Poller) {
+		self.marketHoursOnly = marketHoursOnly
+	}
+}
+
+// WithMaxBackoff caps the wait after consecutive fetches blocked or rate
+// limited by NSE. The default is 30 minutes.
+func WithMaxBackoff(maxBackoff time.Duration) PollerOption {
+	return func(self *Poller) {
+		self.maxBackoff = maxBackoff
+	}
+}
+
+// WithClock sets the clock the poller checks the market hours against.
+func WithClock(now func() time.Time) PollerOption {
+	return func(self *Poller) {
+		self.now = now
+	}
+}
+
+// Poller periodically fetches the option chains of its targets and fans out
+// every new snapshot of an expiry to its subscribers. A snapshot is new when
+// its timestamp differs from the last published snapshot of the expiry, so
+// the unchanged option chain NSE serves after the close is published once.
+//
+// Each symbol is polled in its own goroutine. When NSE blocks or rate limits
+// a fetch, ErrForbidden or ErrRateLimited, the poller backs off
+// exponentially, up to the max back-off, before fetching again. Other failed
+// fetches are retried on the interval.
+type Poller struct {
+	nse             *NSE
+	calendar        *MarketCalendar
+	interval        time.Duration
+	maxBackoff      time.Duration
+	marketHoursOnly bool
+	now             func() time.Time
+
+	mutex       sync.Mutex
+	targets     []*PollTarget
+	subscribers []chan *NseOc
+	callbacks   []func(*NseOc)
+	running     bool
+	stopped     bool
+}
+
+func NewPoller(nse *NSE, options ...PollerOption) *Poller {
+	self := &Poller{
+		nse:             nse,
+		calendar:        NewMarketCalendar(),
+		interval:        kDefaultPollInterval,
+		maxBackoff:      kMaxPollBackoff,
+		marketHoursOnly: true,
+		now:             time.Now,
+		mutex:           sync.Mutex{},
+		targets:         []*PollTarget{},
+		subscribers:     []chan *NseOc{},
+		callbacks:       []func(*NseOc){},
+		running:         false,
+		stopped:         false,
+	}
+	for _, option := range options {
+		option(self)
+	}
+	return self
+}
+
+// AddTarget adds a symbol to poll. Adding a symbol again merges its expiries
+// into the existing target and keeps the shorter interval. Targets added
+// after Run has started are not polled.
+func (self *Poller) AddTarget(target PollTarget) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for _, existing := range self.targets {
+		if existing.Symbol != target.Symbol ||
+			existing.Equity != target.Equity {
+			continue
+		}
+		if len(existing.Expiries) == 0 || len(target.Expiries) == 0 {
+			existing.Expiries = []string{}
+		} else {
+			for _, expiryDate := range target.Expiries {
+				if !containsString(existing.Expiries, expiryDate) {
+					existing.Expiries = append(existing.Expiries, expiryDate)
+				}
+			}
+		}
+		if target.Interval > 0 &&
+			(existing.Interval == 0 || target.Interval < existing.Interval) {
+			existing.Interval = target.Interval
+		}
+		return
+	}
+
+	expiries := make([]string, len(target.Expiries))
+	copy(expiries, target.Expiries)
+	self.targets = append(self.targets, &PollTarget{
+		Symbol:   target.Symbol,
+		Equity:   target.Equity,
+		Expiries: expiries,
+		Interval: target.Interval,
+	})
+}
+
+// Subscribe returns a channel on which every new snapshot is sent. A
+// snapshot is dropped for the subscriber when the buffer of its channel is
+// full, so a slow subscriber does not hold up the others. The channel is
+// closed when Run returns.
+func (self *Poller) Subscribe(buffer int) <-chan *NseOc {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	ch := make(chan *NseOc, buffer)
+	if self.stopped {
+		close(ch)
+		return ch
+	}
+	self.subscribers = append(self.subscribers, ch)
+	return ch
+}
+
+// OnSnapshot registers a callback which is called with every new snapshot.
+// The callbacks run in the goroutine polling the symbol, so they should
+// return quickly and may be called concurrently for different symbols.
+func (self *Poller) OnSnapshot(callback func(*NseOc)) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.callbacks = append(self.callbacks, callback)
+}
+
+// Run polls the targets until the context is done and returns the context
+// error. A Poller can be run only once.
+func (self *Poller) Run(ctx context.Context) error {
+	self.mutex.Lock()
+	if self.running || self.stopped {
+		self.mutex.Unlock()
+		msg := "Poller is already running or has stopped."
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	self.running = true
+	targets := make([]PollTarget, 0, len(self.targets))
+	for _, target := range self.targets {
+		targets = append(targets, *target)
+	}
+	self.mutex.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target PollTarget) {
+			defer wg.Done()
+			self.poll(ctx, target)
+		}(target)
+	}
+	<-ctx.Done()
+	wg.Wait()
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.running = false
+	self.stopped = true
+	for _, ch := range self.subscribers {
+		close(ch)
+	}
+	self.subscribers = []chan *NseOc{}
+	return ctx.Err()
+}
+
+// poll fetches the option chain of the target on its interval until the
+// context is done.
+func (self *Poller) poll(ctx context.Context, target PollTarget) {
+	interval := target.Interval
+	if interval <= 0 {
+		interval = self.interval
+	}
+	// timestamp of the last published snapshot of each expiry
+	timestamps := map[string]string{}
+	backoff := time.Duration(0)
+
+	for {
+		if self.marketHoursOnly {
+			now := self.now()
+			if !self.calendar.IsOpen(now) {
+				nextOpen := self.calendar.NextOpen(now)
+				glog.Info("Market is closed. Polling ", target.Symbol,
+					" again at ", nextOpen)
+				if sleepContext(ctx, nextOpen.Sub(now)) != nil {
+					return
+				}
+				continue
+			}
+		}
+
+		wait := interval
+		if err := self.pollOnce(ctx, target, timestamps); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, ErrForbidden) || errors.Is(err, ErrRateLimited) {
+				backoff = self.nextBackoff(backoff)
+				wait = backoff
+				glog.Error("Polling ", target.Symbol, " was blocked. ",
+					"Backing off for ", backoff, ". ", err)
+			} else {
+				glog.Error("Polling ", target.Symbol, " failed. ", err)
+			}
+		} else {
+			backoff = 0
+		}
+		if sleepContext(ctx, wait) != nil {
+			return
+		}
+	}
+}
+
+// nextBackoff doubles the back-off, starting at 5 minutes and capped at the
+// max back-off.
+func (self *Poller) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff < kMinPollBackoff {
+		backoff = kMinPollBackoff
+	} else {
+		backoff *= 2
+	}
+	if backoff > self.maxBackoff {
+		backoff = self.maxBackoff
+	}
+	return backoff
+}
+
+// pollOnce fetches the option chains of the target and publishes those whose
+// timestamp changed since they were last published.
+func (self *Poller) pollOnce(
+	ctx context.Context,
+	target PollTarget,
+	timestamps map[string]string) error {
+
+	set, err := self.fetch(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	ocs := set.All()
+	if len(target.Expiries) > 0 {
+		ocs = make([]*NseOc, 0, len(target.Expiries))
+		for _, expiryDate := range target.Expiries {
+			oc := set.Get(expiryDate)
+			if oc == nil {
+				msg := fmt.Sprintf("Option chain of %s does not list expiry=%s",
+					target.Symbol, expiryDate)
+				glog.Warning(msg)
+				continue
+			}
+			ocs = append(ocs, oc)
+		}
+	}
+
+	for _, oc := range ocs {
+		if timestamps[oc.ExpiryDate()] == oc.Timestamp() {
+			continue
+		}
+		timestamps[oc.ExpiryDate()] = oc.Timestamp()
+		self.publish(oc)
+	}
+	return nil
+}
+
+func (self *Poller) fetch(
+	ctx context.Context,
+	target PollTarget) (*NseOcSet, error) {
+
+	if target.Equity {
+		return self.nse.FetchEquityOcSetContext(ctx, target.Symbol)
+	}
+	return self.nse.FetchOcSetContext(ctx, target.Symbol)
+}
+
+func (self *Poller) publish(oc *NseOc) {
+	self.mutex.Lock()
+	subscribers := make([]chan *NseOc, len(self.subscribers))
+	copy(subscribers, self.subscribers)
+	callbacks := make([]func(*NseOc), len(self.callbacks))
+	copy(callbacks, self.callbacks)
+	self.mutex.Unlock()
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- oc:
+		default:
+			msg := fmt.Sprintf("Subscriber is full. Dropping %s expiry=%s "+
+				"timestamp=%s", oc.Symbol(), oc.ExpiryDate(), oc.Timestamp())
+			glog.Warning(msg)
+		}
+	}
+	for _, callback := range callbacks {
+		callback(oc)
+	}
+}
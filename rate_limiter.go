@@ -0,0 +1,148 @@
+package nse
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate at which requests may be sent to a host. Burst
+// requests may be sent back to back before the rate applies. A Rate of 0
+// does not limit the host.
+type RateLimit struct {
+	// requests per second
+	Rate  float64
+	Burst int
+}
+
+// The rates NSE tolerates are not published. These are conservative enough
+// for a few goroutines polling the option chains to not be blocked.
+var kDefaultRateLimits = map[string]RateLimit{
+	"www.nseindia.com":      {Rate: 1, Burst: 3},
+	"archives.nseindia.com": {Rate: 2, Burst: 5},
+}
+
+// tokenBucket holds up to burst tokens and is refilled at rate tokens per
+// second. Every request takes a token. A request which finds the bucket
+// empty reserves the next token, leaving the bucket in debt, and waits until
+// the token is refilled, so waiting requests are served in order.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		mutex:  sync.Mutex{},
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (self *tokenBucket) reserve() time.Duration {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := time.Now()
+	self.tokens += now.Sub(self.last).Seconds() * self.rate
+	if self.tokens > self.burst {
+		self.tokens = self.burst
+	}
+	self.last = now
+
+	self.tokens -= 1
+	if self.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-self.tokens / self.rate * float64(time.Second))
+}
+
+// cancel returns a token reserved by a request which gave up waiting.
+func (self *tokenBucket) cancel() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.tokens += 1
+}
+
+// RateLimiter limits the rate of the requests to each host with a token
+// bucket per host. A RateLimiter is safe for concurrent use and may be
+// shared by several NSE clients.
+type RateLimiter struct {
+	mutex   sync.Mutex
+	limits  map[string]RateLimit
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter returns a rate limiter with the default limits of
+// www.nseindia.com and archives.nseindia.com. Other hosts are not limited.
+func NewRateLimiter() *RateLimiter {
+	self := &RateLimiter{
+		mutex:   sync.Mutex{},
+		limits:  map[string]RateLimit{},
+		buckets: map[string]*tokenBucket{},
+	}
+	for host, limit := range kDefaultRateLimits {
+		self.SetLimit(host, limit)
+	}
+	return self
+}
+
+// SetLimit sets the rate limit of the host, for example "www.nseindia.com".
+func (self *RateLimiter) SetLimit(host string, limit RateLimit) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.limits[host] = limit
+	delete(self.buckets, host)
+}
+
+// Limit returns the rate limit of the host.
+func (self *RateLimiter) Limit(host string) RateLimit {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.limits[host]
+}
+
+func (self *RateLimiter) bucket(host string) *tokenBucket {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	limit, ok := self.limits[host]
+	if !ok || limit.Rate <= 0 {
+		return nil
+	}
+	bucket, ok := self.buckets[host]
+	if !ok {
+		bucket = newTokenBucket(limit)
+		self.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// Wait blocks until a request to the host of the URL may be sent or the
+// context is done. It returns the context error in the latter case.
+func (self *RateLimiter) Wait(ctx context.Context, rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	bucket := self.bucket(parsed.Hostname())
+	if bucket == nil {
+		return ctx.Err()
+	}
+	if err := sleepContext(ctx, bucket.reserve()); err != nil {
+		bucket.cancel()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package nse
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryAction is what FetchUrl does when NSE answers with a status other
+// than 200.
+type RetryAction int
+
+const (
+	// Return the HTTPStatusError.
+	RetryFail RetryAction = iota
+	// Retry after the back-off.
+	RetryBackoff
+	// Redo the cookie handshake and retry right away.
+	RetryRefreshCookie
+)
+
+func (self RetryAction) String() string {
+	switch self {
+	case RetryBackoff:
+		return "Backoff"
+	case RetryRefreshCookie:
+		return "RefreshCookie"
+	default:
+		return "Fail"
+	}
+}
+
+// RetryPolicy decides how FetchUrl retries a request NSE did not answer
+// with status 200. Errors of the transport are not retried.
+type RetryPolicy struct {
+	// attempts of a request, the first included
+	MaxAttempts int
+	// The back-off before the nth retry is InitialBackoff * Multiplier^(n-1),
+	// capped at MaxBackoff, and moved by up to Jitter, a fraction, either
+	// way so that clients do not retry in lockstep.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	// cookie handshakes per request before a RetryRefreshCookie status
+	// fails
+	MaxCookieRefreshes int
+
+	// action of each status, the statuses not listed use the server error
+	// action for 5xx and the default action otherwise
+	Actions           map[int]RetryAction
+	ServerErrorAction RetryAction
+	DefaultAction     RetryAction
+}
+
+// DefaultRetryPolicy re-handshakes the cookie twice on 401, fails on 403,
+// and backs off on 429 and 5xx, for up to 5 attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:        5,
+		InitialBackoff:     time.Second,
+		MaxBackoff:         30 * time.Second,
+		Multiplier:         2,
+		Jitter:             0.2,
+		MaxCookieRefreshes: 2,
+		Actions: map[int]RetryAction{
+			http.StatusUnauthorized:    RetryRefreshCookie,
+			http.StatusForbidden:       RetryFail,
+			http.StatusTooManyRequests: RetryBackoff,
+		},
+		ServerErrorAction: RetryBackoff,
+		DefaultAction:     RetryFail,
+	}
+}
+
+// Action returns the action of the status.
+func (self RetryPolicy) Action(statusCode int) RetryAction {
+	if action, ok := self.Actions[statusCode]; ok {
+		return action
+	}
+	if statusCode >= 500 && statusCode < 600 {
+		return self.ServerErrorAction
+	}
+	return self.DefaultAction
+}
+
+// Backoff returns the back-off before the nth retry, n starting at 1.
+func (self RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := self.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(self.InitialBackoff) *
+		math.Pow(multiplier, float64(retry-1))
+	if self.MaxBackoff > 0 && backoff > float64(self.MaxBackoff) {
+		backoff = float64(self.MaxBackoff)
+	}
+	if self.Jitter > 0 {
+		backoff *= 1 + self.Jitter*(2*randFloat64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+var (
+	kRandMutex sync.Mutex
+	kRand      = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func randFloat64() float64 {
+	kRandMutex.Lock()
+	defer kRandMutex.Unlock()
+	return kRand.Float64()
+}
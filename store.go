@@ -0,0 +1,417 @@
+package nse
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	kStoreSegmentSuffix = ".jsonl.gz"
+	kStoreSegmentLayout = "2006-01-02"
+)
+
+// OcSnapshot is the stored form of an NseOc, one JSON line per snapshot.
+type OcSnapshot struct {
+	Symbol          string          `json:"symbol"`
+	ExpiryDate      string          `json:"expiryDate"`
+	Timestamp       string          `json:"timestamp"`
+	UnderlyingValue float64         `json:"underlyingValue"`
+	StrikeStep      float64         `json:"strikeStep"`
+	Data            []*OcDataRecord `json:"data"`
+}
+
+// NewOcSnapshot returns the snapshot of every strike of the option chain.
+func NewOcSnapshot(oc *NseOc) *OcSnapshot {
+	return &OcSnapshot{
+		Symbol:          oc.symbol,
+		ExpiryDate:      oc.expiryDate,
+		Timestamp:       oc.timestamp,
+		UnderlyingValue: oc.underlyingValue,
+		StrikeStep:      oc.strikeStep,
+		Data:            oc.DataRecords(),
+	}
+}
+
+// NseOc rebuilds the option chain of the snapshot.
+func (self *OcSnapshot) NseOc() *NseOc {
+	oc := NewNseOc(self.Symbol, self.ExpiryDate, self.Timestamp,
+		self.UnderlyingValue)
+	oc.SetOcDataRecords(self.Data)
+	oc.SetStrikeStep(self.StrikeStep)
+	return oc
+}
+
+// DataRecords returns the record of every strike in ascending order of
+// strike.
+func (self *NseOc) DataRecords() []*OcDataRecord {
+	records := make([]*OcDataRecord, 0, len(self.strikes))
+	for _, strike := range self.strikes {
+		row := self.rows[strike]
+		record := &OcDataRecord{
+			StrikePrice: strike,
+			ExpiryDate:  self.expiryDate,
+			Ce:          nil,
+			Pe:          nil,
+		}
+		if row.Ce != nil {
+			record.Ce = row.Ce.Contract()
+		}
+		if row.Pe != nil {
+			record.Pe = row.Pe.Contract()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// OcStore stores option chain snapshots on the local disk, laid out as
+// dir/SYMBOL/EXPIRY/YYYY-MM-DD.jsonl.gz with one segment per symbol, expiry
+// and trading day (IST). Every append writes a new gzip member holding one
+// JSON line, so a segment is a valid multi-member gzip file after each append
+// and a crash loses at most the snapshot being written.
+type OcStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewOcStore opens the store in dir, creating the directory if needed.
+func NewOcStore(dir string) (*OcStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		msg := fmt.Sprintf("Creating store dir=%s failed with error=%s", dir,
+			err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	return &OcStore{
+		dir: dir,
+	}, nil
+}
+
+func (self *OcStore) Dir() string {
+	return self.dir
+}
+
+func (self *OcStore) expiryDir(symbol string, expiryDate string) string {
+	return filepath.Join(self.dir, escapeDirName(symbol),
+		escapeDirName(expiryDate))
+}
+
+// escapeDirName escapes a symbol or an expiry date into the name of a
+// directory. The dots are escaped too, as url.PathEscape leaves them alone and
+// a name of ".." would escape the store.
+func escapeDirName(name string) string {
+	return strings.ReplaceAll(url.PathEscape(name), ".", "%2E")
+}
+
+// Append appends the snapshot of every strike of the option chain.
+func (self *OcStore) Append(oc *NseOc) error {
+	snapshot := NewOcSnapshot(oc)
+	snapshotTime, err := oc.Time()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing timestamp=%s failed with error=%s",
+			oc.timestamp, err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		msg := fmt.Sprintf("Encoding snapshot of %s expiry=%s failed with "+
+			"error=%s", oc.symbol, oc.expiryDate, err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	line = append(line, '\n')
+
+	dir := self.expiryDir(oc.symbol, oc.expiryDate)
+	path := filepath.Join(dir,
+		snapshotTime.In(IST).Format(kStoreSegmentLayout)+kStoreSegmentSuffix)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		msg := fmt.Sprintf("Creating dir=%s failed with error=%s", dir, err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	if err := appendGzipMember(path, line); err != nil {
+		msg := fmt.Sprintf("Appending to segment=%s failed with error=%s",
+			path, err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// AppendSet appends the snapshot of every expiry of the set.
+func (self *OcStore) AppendSet(set *NseOcSet) error {
+	for _, oc := range set.All() {
+		if err := self.Append(oc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendGzipMember(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Symbols returns the symbols in the store in ascending order.
+func (self *OcStore) Symbols() ([]string, error) {
+	return listDir(self.dir)
+}
+
+// Expiries returns the expiry dates of the symbol in the store in ascending
+// order of expiry.
+func (self *OcStore) Expiries(symbol string) ([]string, error) {
+	expiries, err := listDir(filepath.Join(self.dir, escapeDirName(symbol)))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(expiries, func(i, j int) bool {
+		ti, erri := ParseExpiryDate(expiries[i])
+		tj, errj := ParseExpiryDate(expiries[j])
+		if erri != nil || errj != nil {
+			return expiries[i] < expiries[j]
+		}
+		return ti.Before(tj)
+	})
+	return expiries, nil
+}
+
+// listDir returns the unescaped names of the directories in dir, or nothing
+// when dir does not exist.
+func listDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Listing dir=%s failed with error=%s", dir, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// OcQuery selects snapshots from the store. Strikes limits the strikes of the
+// returned option chains, all strikes when empty. From and To bound the
+// snapshot time, both inclusive, and are ignored when zero.
+type OcQuery struct {
+	Symbol     string
+	ExpiryDate string
+	Strikes    []float64
+	From       time.Time
+	To         time.Time
+}
+
+func (self *OcQuery) contains(snapshotTime time.Time) bool {
+	if !self.From.IsZero() && snapshotTime.Before(self.From) {
+		return false
+	}
+	if !self.To.IsZero() && snapshotTime.After(self.To) {
+		return false
+	}
+	return true
+}
+
+// containsDay tells whether the segment of the day can hold snapshots in the
+// time range.
+func (self *OcQuery) containsDay(day string) bool {
+	// The segments are named after the IST day, which sorts as a string.
+	if !self.From.IsZero() &&
+		day < self.From.In(IST).Format(kStoreSegmentLayout) {
+		return false
+	}
+	if !self.To.IsZero() &&
+		day > self.To.In(IST).Format(kStoreSegmentLayout) {
+		return false
+	}
+	return true
+}
+
+// Query returns the snapshots matching the query in ascending order of time.
+func (self *OcStore) Query(query OcQuery) ([]*NseOc, error) {
+	dir := self.expiryDir(query.Symbol, query.ExpiryDate)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*NseOc{}, nil
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Listing dir=%s failed with error=%s", dir, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	ocs := []*NseOc{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, kStoreSegmentSuffix) {
+			continue
+		}
+		if !query.containsDay(strings.TrimSuffix(name, kStoreSegmentSuffix)) {
+			continue
+		}
+		snapshots, err := self.readSegment(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			snapshotTime, err := ParseOcTimestamp(snapshot.Timestamp)
+			if err != nil || !query.contains(snapshotTime) {
+				continue
+			}
+			oc := snapshot.NseOc()
+			if len(query.Strikes) > 0 {
+				oc = oc.NseOcForStrikes(query.Strikes)
+			}
+			ocs = append(ocs, oc)
+		}
+	}
+
+	sort.SliceStable(ocs, func(i, j int) bool {
+		ti, _ := ocs[i].Time()
+		tj, _ := ocs[j].Time()
+		return ti.Before(tj)
+	})
+	return ocs, nil
+}
+
+// readSegment returns the snapshots of a segment. A segment truncated by a
+// crash while appending returns the snapshots before the truncated one.
+func (self *OcStore) readSegment(path string) ([]*OcSnapshot, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		msg := fmt.Sprintf("Opening segment=%s failed with error=%s", path, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		msg := fmt.Sprintf("Reading segment=%s failed with error=%s", path, err)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	defer reader.Close()
+
+	snapshots := []*OcSnapshot{}
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			msg := fmt.Sprintf("Segment=%s is truncated after %d snapshots, "+
+				"error=%s", path, len(snapshots), err)
+			glog.Warning(msg)
+			break
+		}
+		snapshot := &OcSnapshot{}
+		if err := json.Unmarshal(line, snapshot); err != nil {
+			msg := fmt.Sprintf("Skipping snapshot %d of segment=%s, error=%s",
+				len(snapshots), path, err)
+			glog.Warning(msg)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// StrikePoint is the CE and the PE of a strike in one snapshot. A side is
+// nil when the contract is not listed.
+type StrikePoint struct {
+	Time            time.Time
+	UnderlyingValue float64
+	Ce              *OcContract
+	Pe              *OcContract
+}
+
+// StrikeHistory returns the CE and PE of the strike in every snapshot of the
+// symbol and expiry between from and to, in ascending order of time. Zero
+// from or to leave the range open.
+func (self *OcStore) StrikeHistory(
+	symbol string,
+	expiryDate string,
+	strike float64,
+	from time.Time,
+	to time.Time) ([]StrikePoint, error) {
+
+	ocs, err := self.Query(OcQuery{
+		Symbol:     symbol,
+		ExpiryDate: expiryDate,
+		Strikes:    []float64{strike},
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	points := []StrikePoint{}
+	for _, oc := range ocs {
+		row := oc.Row(strike)
+		if row == nil {
+			continue
+		}
+		snapshotTime, _ := oc.Time()
+		point := StrikePoint{
+			Time:            snapshotTime,
+			UnderlyingValue: oc.UnderlyingValue(),
+			Ce:              nil,
+			Pe:              nil,
+		}
+		if row.Ce != nil {
+			point.Ce = row.Ce.Contract()
+		}
+		if row.Pe != nil {
+			point.Pe = row.Pe.Contract()
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
@@ -0,0 +1,505 @@
+package nse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/joshi-prasad/nse/bs"
+)
+
+// Side tells a bought leg apart from a sold one.
+type Side int
+
+const (
+	Buy Side = iota
+	Sell
+)
+
+func (self Side) String() string {
+	if self == Buy {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+// sign returns 1 for a bought leg and -1 for a sold one.
+func (self Side) sign() float64 {
+	if self == Buy {
+		return 1
+	}
+	return -1
+}
+
+// Leg is a position in one option contract. IV is a fraction and is used to
+// mark the leg to model before its expiry, with the model the IV was solved
+// with.
+type Leg struct {
+	OptionType OptionType
+	Strike     float64
+	Expiry     time.Time
+	Side       Side
+	Lots       int
+	EntryPrice float64
+	IV         float64
+
+	Model bs.Model
+	// continuous dividend yield in percent, used by Merton
+	DividendYield float64
+	// annual rate, as a fraction, at which the futures trade over the spot,
+	// used by Black-76 to derive the futures price from the spot
+	FuturesCarry float64
+}
+
+func (self *Leg) bsOptionType() bs.OptionType {
+	if self.OptionType == OptionTypeCe {
+		return bs.Ce
+	}
+	return bs.Pe
+}
+
+// quantity returns the number of units of the leg, negative when sold.
+func (self *Leg) quantity(lotSize int) float64 {
+	return self.Side.sign() * float64(self.Lots*lotSize)
+}
+
+func (self *Leg) intrinsic(spot float64) float64 {
+	if self.OptionType == OptionTypeCe {
+		return math.Max(0, spot-self.Strike)
+	}
+	return math.Max(0, self.Strike-spot)
+}
+
+// StrategyGreeks are the greeks of the whole position, the sum of the greeks
+// of every leg times its signed quantity. Delta is in units of the underlying
+// and Gamma is the change in Delta per point of the spot. Theta, Vega and Rho
+// are in rupees, Theta per day and Vega and Rho per 1% change.
+type StrategyGreeks struct {
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+	Rho   float64
+}
+
+// Strategy is a set of option legs on one symbol. All the legs use the lot
+// size of the strategy.
+type Strategy struct {
+	name         string
+	symbol       string
+	lotSize      int
+	riskFreeRate float64
+	legs         []Leg
+}
+
+func NewStrategy(name string, symbol string, lotSize int) *Strategy {
+	return &Strategy{
+		name:         name,
+		symbol:       symbol,
+		lotSize:      lotSize,
+		riskFreeRate: kDefaultRiskFreeRate,
+		legs:         []Leg{},
+	}
+}
+
+func (self *Strategy) Name() string {
+	return self.name
+}
+
+func (self *Strategy) Symbol() string {
+	return self.symbol
+}
+
+func (self *Strategy) LotSize() int {
+	return self.lotSize
+}
+
+// SetRiskFreeRate sets the risk-free interest rate, in percent, used to mark
+// the legs to model.
+func (self *Strategy) SetRiskFreeRate(rate float64) {
+	self.riskFreeRate = rate
+}
+
+func (self *Strategy) RiskFreeRate() float64 {
+	return self.riskFreeRate
+}
+
+func (self *Strategy) Legs() []Leg {
+	legs := make([]Leg, len(self.legs))
+	copy(legs, self.legs)
+	return legs
+}
+
+func (self *Strategy) AddLeg(leg Leg) {
+	self.legs = append(self.legs, leg)
+}
+
+// AddLegFromOc adds a leg on a contract of the option chain entered at its
+// LTP. The IV of the leg is solved from the LTP with the pricing model of the
+// option chain, falling back to the IV reported by NSE, and the leg is marked
+// with the same model.
+func (self *Strategy) AddLegFromOc(
+	oc *NseOc,
+	optionType OptionType,
+	strike float64,
+	side Side,
+	lots int) error {
+
+	row := oc.Row(strike)
+	if row == nil || row.Contract(optionType) == nil {
+		msg := fmt.Sprintf("Strike=%g %s not found in the option chain of %s "+
+			"expiry=%s.", strike, optionType, oc.Symbol(), oc.ExpiryDate())
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+	contract := row.Contract(optionType)
+	expiry, err := oc.ExpiryTime()
+	if err != nil {
+		msg := fmt.Sprintf("Parsing expiry=%s failed with error=%s",
+			oc.ExpiryDate(), err)
+		glog.Error(msg)
+		return errors.New(msg)
+	}
+
+	iv := contract.ImpliedVolatility() / 100
+	if greeks, err := oc.Greeks(strike); err == nil {
+		solved := greeks.Ce
+		if optionType == OptionTypePe {
+			solved = greeks.Pe
+		}
+		if solved != nil {
+			iv = solved.IV
+		}
+	}
+
+	futuresCarry := 0.0
+	if oc.PricingModel() == bs.Black76 {
+		futuresCarry, err = ocFuturesCarry(oc)
+		if err != nil {
+			return err
+		}
+	}
+
+	self.AddLeg(Leg{
+		OptionType:    optionType,
+		Strike:        strike,
+		Expiry:        expiry,
+		Side:          side,
+		Lots:          lots,
+		EntryPrice:    contract.Ltp(),
+		IV:            iv,
+		Model:         oc.PricingModel(),
+		DividendYield: oc.DividendYield(),
+		FuturesCarry:  futuresCarry,
+	})
+	return nil
+}
+
+// ocFuturesCarry returns the annual rate at which the futures price of the
+// option chain trades over its underlying value.
+func ocFuturesCarry(oc *NseOc) (float64, error) {
+	daysToExpiry, futuresPrice, err := oc.greeksInputs()
+	if err != nil {
+		return 0, err
+	}
+	if daysToExpiry <= 0 || oc.UnderlyingValue() <= 0 || futuresPrice <= 0 {
+		return 0, nil
+	}
+	return math.Log(futuresPrice/oc.UnderlyingValue()) /
+		(daysToExpiry / 365), nil
+}
+
+// NetPremium returns the premium received for the strategy, negative when
+// the premium is paid.
+func (self *Strategy) NetPremium() float64 {
+	premium := 0.0
+	for ii := range self.legs {
+		leg := &self.legs[ii]
+		premium -= leg.quantity(self.lotSize) * leg.EntryPrice
+	}
+	return premium
+}
+
+// PayoffAtExpiry returns the profit, or loss when negative, if the underlying
+// settles at spot. Every leg is settled at spot, so for calendar spreads use
+// PnL at the first expiry instead.
+func (self *Strategy) PayoffAtExpiry(spot float64) float64 {
+	payoff := 0.0
+	for ii := range self.legs {
+		leg := &self.legs[ii]
+		payoff += leg.quantity(self.lotSize) *
+			(leg.intrinsic(spot) - leg.EntryPrice)
+	}
+	return payoff
+}
+
+// legModel returns the model of the leg at the spot and the time, nil when
+// the leg has expired. The second value is the change in the asset price of
+// the model per point of the spot, the futures growth for Black-76 and 1
+// otherwise.
+func (self *Strategy) legModel(
+	leg *Leg,
+	spot float64,
+	at time.Time) (*bs.BlackSchools, float64) {
+
+	if !at.Before(leg.Expiry) {
+		return nil, 0
+	}
+	daysToExpiry := leg.Expiry.Sub(at).Minutes() / kMinutesPerDay
+	switch leg.Model {
+	case bs.Merton:
+		return bs.NewMerton(spot, leg.Strike, self.riskFreeRate,
+			leg.DividendYield, daysToExpiry, leg.IV*100, 0, 0), 1
+	case bs.Black76:
+		growth := math.Exp(leg.FuturesCarry * daysToExpiry / 365)
+		return bs.NewBlack76(spot*growth, leg.Strike, self.riskFreeRate,
+			daysToExpiry, leg.IV*100, 0, 0), growth
+	default:
+		return bs.NewBlackSchools(spot, leg.Strike, self.riskFreeRate,
+			daysToExpiry, leg.IV*100, 0, 0), 1
+	}
+}
+
+// PnL returns the mark-to-model profit, or loss when negative, at the spot
+// and the time. The legs are priced with their model at their IV, expired
+// legs are settled at spot.
+func (self *Strategy) PnL(spot float64, at time.Time) float64 {
+	pnl := 0.0
+	for ii := range self.legs {
+		leg := &self.legs[ii]
+		value := leg.intrinsic(spot)
+		if model, _ := self.legModel(leg, spot, at); model != nil {
+			price, err := model.Price(leg.bsOptionType(), model.Volatility)
+			if err == nil {
+				value = price
+			}
+		}
+		pnl += leg.quantity(self.lotSize) * (value - leg.EntryPrice)
+	}
+	return pnl
+}
+
+// Greeks returns the net greeks of the strategy at the spot and the time.
+// Expired legs have no greeks.
+func (self *Strategy) Greeks(spot float64, at time.Time) StrategyGreeks {
+	net := StrategyGreeks{}
+	for ii := range self.legs {
+		leg := &self.legs[ii]
+		model, growth := self.legModel(leg, spot, at)
+		if model == nil {
+			continue
+		}
+		greeks, err := model.Greeks(leg.bsOptionType())
+		if err != nil {
+			continue
+		}
+		quantity := leg.quantity(self.lotSize)
+		net.Delta += quantity * greeks.Delta * growth
+		net.Gamma += quantity * greeks.Gamma * growth * growth
+		net.Theta += quantity * greeks.Theta
+		net.Vega += quantity * greeks.Vega
+		net.Rho += quantity * greeks.Rho
+	}
+	return net
+}
+
+// payoffPoints returns the spots at which the payoff at expiry can change
+// slope, 0 and every strike, in ascending order.
+func (self *Strategy) payoffPoints() []float64 {
+	points := []float64{0}
+	seen := map[float64]bool{}
+	for ii := range self.legs {
+		strike := self.legs[ii].Strike
+		if !seen[strike] && strike > 0 {
+			seen[strike] = true
+			points = append(points, strike)
+		}
+	}
+	sort.Float64s(points)
+	return points
+}
+
+// slopeAbove returns the slope of the payoff at expiry above the highest
+// strike, where only the CE legs are in the money.
+func (self *Strategy) slopeAbove() float64 {
+	slope := 0.0
+	for ii := range self.legs {
+		leg := &self.legs[ii]
+		if leg.OptionType == OptionTypeCe {
+			slope += leg.quantity(self.lotSize)
+		}
+	}
+	return slope
+}
+
+// Breakevens returns the spots, in ascending order, at which the payoff at
+// expiry is 0. The payoff is linear between the strikes, so each breakeven
+// is found by interpolating between the strikes around it.
+func (self *Strategy) Breakevens() []float64 {
+	points := self.payoffPoints()
+	breakevens := []float64{}
+	add := func(spot float64) {
+		last := len(breakevens) - 1
+		if last < 0 || math.Abs(breakevens[last]-spot) > 1e-9 {
+			breakevens = append(breakevens, spot)
+		}
+	}
+
+	prevSpot := points[0]
+	prevPayoff := self.PayoffAtExpiry(prevSpot)
+	if prevPayoff == 0 {
+		add(prevSpot)
+	}
+	for _, spot := range points[1:] {
+		payoff := self.PayoffAtExpiry(spot)
+		if payoff == 0 {
+			add(spot)
+		} else if prevPayoff*payoff < 0 {
+			add(prevSpot + (spot-prevSpot)*prevPayoff/(prevPayoff-payoff))
+		}
+		prevSpot, prevPayoff = spot, payoff
+	}
+
+	slope := self.slopeAbove()
+	if slope != 0 && prevPayoff*slope < 0 {
+		add(prevSpot - prevPayoff/slope)
+	}
+	return breakevens
+}
+
+// MaxProfit returns the highest payoff at expiry. It returns false when the
+// profit is unbounded, that is it keeps rising with the spot.
+func (self *Strategy) MaxProfit() (float64, bool) {
+	if self.slopeAbove() > 0 {
+		return math.Inf(1), false
+	}
+	maxPayoff := math.Inf(-1)
+	for _, spot := range self.payoffPoints() {
+		maxPayoff = math.Max(maxPayoff, self.PayoffAtExpiry(spot))
+	}
+	return maxPayoff, true
+}
+
+// MaxLoss returns the largest loss at expiry as a positive amount, 0 when the
+// strategy cannot lose. It returns false when the loss is unbounded, that is
+// it keeps growing with the spot.
+func (self *Strategy) MaxLoss() (float64, bool) {
+	if self.slopeAbove() < 0 {
+		return math.Inf(1), false
+	}
+	minPayoff := math.Inf(1)
+	for _, spot := range self.payoffPoints() {
+		minPayoff = math.Min(minPayoff, self.PayoffAtExpiry(spot))
+	}
+	return math.Max(0, -minPayoff), true
+}
+
+// RiskReward returns the max profit over the max loss. It returns false when
+// either one is unbounded or the strategy cannot lose.
+func (self *Strategy) RiskReward() (float64, bool) {
+	profit, profitBounded := self.MaxProfit()
+	loss, lossBounded := self.MaxLoss()
+	if !profitBounded || !lossBounded || loss == 0 {
+		return 0, false
+	}
+	return profit / loss, true
+}
+
+type legSpec struct {
+	optionType OptionType
+	strike     float64
+	side       Side
+	lots       int
+}
+
+func newStrategyFromOc(
+	name string,
+	oc *NseOc,
+	lotSize int,
+	specs []legSpec) (*Strategy, error) {
+
+	strategy := NewStrategy(name, oc.Symbol(), lotSize)
+	strategy.SetRiskFreeRate(oc.RiskFreeRate())
+	for _, spec := range specs {
+		err := strategy.AddLegFromOc(oc, spec.optionType, spec.strike,
+			spec.side, spec.lots)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return strategy, nil
+}
+
+// NewStraddle buys, or sells, the CE and the PE of the strike.
+func NewStraddle(
+	oc *NseOc,
+	strike float64,
+	side Side,
+	lots int,
+	lotSize int) (*Strategy, error) {
+
+	return newStrategyFromOc("Straddle", oc, lotSize, []legSpec{
+		{optionType: OptionTypeCe, strike: strike, side: side, lots: lots},
+		{optionType: OptionTypePe, strike: strike, side: side, lots: lots},
+	})
+}
+
+// NewStrangle buys, or sells, the PE of peStrike and the CE of ceStrike.
+func NewStrangle(
+	oc *NseOc,
+	peStrike float64,
+	ceStrike float64,
+	side Side,
+	lots int,
+	lotSize int) (*Strategy, error) {
+
+	return newStrategyFromOc("Strangle", oc, lotSize, []legSpec{
+		{optionType: OptionTypePe, strike: peStrike, side: side, lots: lots},
+		{optionType: OptionTypeCe, strike: ceStrike, side: side, lots: lots},
+	})
+}
+
+// NewIronCondor sells the PE of shortPeStrike and the CE of shortCeStrike
+// and buys the PE of longPeStrike and the CE of longCeStrike as the wings.
+func NewIronCondor(
+	oc *NseOc,
+	longPeStrike float64,
+	shortPeStrike float64,
+	shortCeStrike float64,
+	longCeStrike float64,
+	lots int,
+	lotSize int) (*Strategy, error) {
+
+	return newStrategyFromOc("Iron Condor", oc, lotSize, []legSpec{
+		{optionType: OptionTypePe, strike: longPeStrike, side: Buy,
+			lots: lots},
+		{optionType: OptionTypePe, strike: shortPeStrike, side: Sell,
+			lots: lots},
+		{optionType: OptionTypeCe, strike: shortCeStrike, side: Sell,
+			lots: lots},
+		{optionType: OptionTypeCe, strike: longCeStrike, side: Buy,
+			lots: lots},
+	})
+}
+
+// NewRatioSpread buys buyLots of the contract at buyStrike and sells
+// sellLots of the contract of the same type at sellStrike.
+func NewRatioSpread(
+	oc *NseOc,
+	optionType OptionType,
+	buyStrike float64,
+	sellStrike float64,
+	buyLots int,
+	sellLots int,
+	lotSize int) (*Strategy, error) {
+
+	return newStrategyFromOc("Ratio Spread", oc, lotSize, []legSpec{
+		{optionType: optionType, strike: buyStrike, side: Buy,
+			lots: buyLots},
+		{optionType: optionType, strike: sellStrike, side: Sell,
+			lots: sellLots},
+	})
+}
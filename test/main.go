@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/opts"
@@ -16,20 +19,45 @@ var kUpdateFuturesData = flag.Bool(
 	false,
 	"Update the futures data.")
 
+var kBlackSchoolsDemo = flag.Bool(
+	"black_schools_demo",
+	false,
+	"Print the greeks of a sample BANKNIFTY strike.")
+
+var kHolidays = flag.String(
+	"holidays",
+	"",
+	"Comma separated exchange holidays, like 26-Jan-2023,07-Mar-2023.")
+
+var kPollAlways = flag.Bool(
+	"poll_always",
+	false,
+	"Poll the option chain outside the market hours as well.")
+
+var kStoreDir = flag.String(
+	"store_dir",
+	"",
+	"Directory to store the option chain snapshots in. Not stored when empty.")
+
 func main() {
 	flag.Set("alsologtostderr", "true")
 	flag.Parse()
 
-	// strikeCeOi := map[int32][]opts.LineData{}
-	// strikePeOi := map[int32][]opts.LineData{}
-	// strikeCeChangeOi := map[int32][]opts.LineData{}
-	// strikePeChangeOi := map[int32][]opts.LineData{}
+	// strikeCeOi := map[float64][]opts.LineData{}
+	// strikePeOi := map[float64][]opts.LineData{}
+	// strikeCeChangeOi := map[float64][]opts.LineData{}
+	// strikePeChangeOi := map[float64][]opts.LineData{}
 	// underlyingAssetPrices := []opts.LineData{}
 	// xaxis := []string{}
 
 	nseObj := nse.NewNSE()
 	fmt.Println(nseObj)
 
+	if *kBlackSchoolsDemo {
+		RunBlackSchoolsDemo()
+		return
+	}
+
 	if *kUpdateFuturesData == true {
 		UpdateFOData(nseObj)
 		return
@@ -38,15 +66,44 @@ func main() {
 	// webServer := NewWebServer(8080)
 	// webServer.Serve()
 
-	for {
-		oc, err := nseObj.FetchBankNiftyOc("01-Jun-2023")
+	var store *nse.OcStore
+	if *kStoreDir != "" {
+		var err error
+		store, err = nse.NewOcStore(*kStoreDir)
 		if err != nil {
-			glog.Error("Failed to fetch bank nifty OC.", err)
-			return
+			glog.Fatal("Failed to open the snapshot store. ", err)
+		}
+	}
+
+	calendar := nse.NewMarketCalendar()
+	if *kHolidays != "" {
+		for _, holiday := range strings.Split(*kHolidays, ",") {
+			if err := calendar.AddHolidayDate(holiday); err != nil {
+				glog.Fatal("Failed to parse the holidays. ", err)
+			}
+		}
+	}
+	poller := nse.NewPoller(
+		nseObj,
+		nse.WithMarketCalendar(calendar),
+		nse.WithMarketHoursOnly(!*kPollAlways))
+	poller.AddTarget(nse.PollTarget{
+		Symbol:   "BANKNIFTY",
+		Equity:   false,
+		Expiries: []string{"01-Jun-2023"},
+		Interval: 3 * time.Minute,
+	})
+
+	var prevOcShort *nse.NseShortOc
+	poller.OnSnapshot(func(oc *nse.NseOc) {
+		if store != nil {
+			if err := store.Append(oc); err != nil {
+				glog.Error("Failed to store bank nifty OC.", err)
+			}
 		}
 
 		fmt.Println("==============================================")
-		fmt.Println("Time ", time.Now())
+		fmt.Println("Time ", oc.Timestamp())
 		fmt.Println("Underlying Value ", oc.UnderlyingValue())
 		fmt.Println("ATM Strike Price ", oc.AtmStrike())
 		fmt.Println("Total PCR is ", oc.Pcr())
@@ -62,6 +119,13 @@ func main() {
 		ocShort.WeightedRank()
 		ocShort.PrintTable()
 
+		if prevOcShort != nil {
+			if diff, err := ocShort.Diff(prevOcShort); err == nil {
+				diff.PrintTable()
+			}
+		}
+		prevOcShort = ocShort
+
 		// xaxis = append(xaxis, time.Now().Format("15:04"))
 		// underlyingAssetPrices = append(
 		// 	underlyingAssetPrices,
@@ -80,13 +144,15 @@ func main() {
 		// graphs = append(graphs, graph)
 		// for _, strike := range strikes8 {
 		// 	graph.SetXAxis(xaxis)
-		// 	graph.AddYAxis(fmt.Sprintf("%d_ce_oi", strike), strikeCeOi[strike])
-		// 	graph.AddYAxis(fmt.Sprintf("%d_pe_oi", strike), strikePeOi[strike])
+		// 	graph.AddYAxis(fmt.Sprintf("%g_ce_oi", strike), strikeCeOi[strike])
+		// 	graph.AddYAxis(fmt.Sprintf("%g_pe_oi", strike), strikePeOi[strike])
 		// }
 		// webServer.SetLineGraphs(graphs)
+	})
 
-		time.Sleep(3 * time.Minute)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	poller.Run(ctx)
 }
 
 func UpdateFOData(nseObj *nse.NSE) {
@@ -232,7 +298,7 @@ func FindClientRecord(
 	return nil
 }
 
-func AppendToStrike(strike int32, value float64, dst map[int32][]opts.LineData) {
+func AppendToStrike(strike float64, value float64, dst map[float64][]opts.LineData) {
 	if _, ok := dst[strike]; !ok {
 		dst[strike] = []opts.LineData{}
 	}
@@ -0,0 +1,315 @@
+package nse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+const (
+	kFixtureMetaSuffix = ".json"
+	kFixtureBodySuffix = ".body"
+)
+
+// nseFixture is a single recorded request/response pair. The metadata is
+// stored as <seq>.json and the decoded response body as <seq>.body next to it.
+type nseFixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Gzip       bool        `json:"gzip"`
+
+	body []byte
+}
+
+func (self *nseFixture) key() string {
+	return fixtureKey(self.Method, self.URL)
+}
+
+func fixtureKey(method string, url string) string {
+	return method + " " + url
+}
+
+func fixtureBase(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d", seq))
+}
+
+// RecordingTransport is an http.RoundTripper that forwards requests to the
+// next transport and saves every request/response pair into a directory of
+// fixtures. The fixtures can be served back using ReplayTransport.
+type RecordingTransport struct {
+	dir  string
+	next http.RoundTripper
+
+	mutex sync.Mutex
+	seq   int
+}
+
+// NewRecordingTransport creates a recording transport which writes fixtures
+// into dir. If next is nil http.DefaultTransport is used. Recording into a
+// directory that already holds fixtures appends to them.
+func NewRecordingTransport(
+	dir string,
+	next http.RoundTripper) (*RecordingTransport, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		msg := fmt.Sprintf("Creating fixture directory=%s failed with error=%s",
+			dir, err)
+		glog.Error(msg)
+		return nil, err
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	existing, err := fixtureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingTransport{
+		dir:  dir,
+		next: next,
+		seq:  len(existing),
+	}, nil
+}
+
+func (self *RecordingTransport) RoundTrip(
+	req *http.Request) (*http.Response, error) {
+
+	resp, err := self.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+	fixture := &nseFixture{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Gzip:       resp.Header.Get("Content-Encoding") == "gzip",
+		body:       raw,
+	}
+	if fixture.Gzip && len(raw) > 0 {
+		fixture.body, err = gunzip(raw)
+		if err != nil {
+			msg := fmt.Sprintf("Decoding gzip body of URL=%s failed with error=%s",
+				fixture.URL, err)
+			glog.Error(msg)
+			return nil, err
+		}
+	}
+	fixture.Header.Del("Content-Encoding")
+	fixture.Header.Del("Content-Length")
+
+	if err := self.save(fixture); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (self *RecordingTransport) save(fixture *nseFixture) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	meta, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	base := fixtureBase(self.dir, self.seq+1)
+	if err := ioutil.WriteFile(base+kFixtureBodySuffix, fixture.body, 0644); err != nil {
+		msg := fmt.Sprintf("Writing fixture body failed with error=%s", err)
+		glog.Error(msg)
+		return err
+	}
+	if err := ioutil.WriteFile(base+kFixtureMetaSuffix, meta, 0644); err != nil {
+		msg := fmt.Sprintf("Writing fixture failed with error=%s", err)
+		glog.Error(msg)
+		return err
+	}
+	self.seq += 1
+	return nil
+}
+
+// ReplayTransport is an http.RoundTripper that serves the fixtures saved by
+// RecordingTransport. Responses for the same method and URL are served in the
+// order in which they were recorded, so a replayed session sees exactly the
+// sequence of responses the recorded session saw.
+type ReplayTransport struct {
+	mutex    sync.Mutex
+	fixtures map[string][]*nseFixture
+	served   map[string]int
+}
+
+// NewReplayTransport loads all the fixtures in dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	files, err := fixtureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		msg := fmt.Sprintf("No fixtures found in directory=%s.", dir)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+
+	fixtures := map[string][]*nseFixture{}
+	for _, file := range files {
+		fixture, err := loadFixture(file)
+		if err != nil {
+			return nil, err
+		}
+		key := fixture.key()
+		fixtures[key] = append(fixtures[key], fixture)
+	}
+	return &ReplayTransport{
+		fixtures: fixtures,
+		served:   map[string]int{},
+	}, nil
+}
+
+// Rewind restarts the replay from the first recorded response.
+func (self *ReplayTransport) Rewind() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.served = map[string]int{}
+}
+
+// Remaining returns the number of recorded responses not served yet.
+func (self *ReplayTransport) Remaining() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	remaining := 0
+	for key, fixtures := range self.fixtures {
+		remaining += len(fixtures) - self.served[key]
+	}
+	return remaining
+}
+
+func (self *ReplayTransport) RoundTrip(
+	req *http.Request) (*http.Response, error) {
+
+	key := fixtureKey(req.Method, req.URL.String())
+
+	self.mutex.Lock()
+	fixtures := self.fixtures[key]
+	index := self.served[key]
+	if index < len(fixtures) {
+		self.served[key] = index + 1
+	}
+	self.mutex.Unlock()
+
+	if index >= len(fixtures) {
+		msg := fmt.Sprintf("No recorded response left for %s.", key)
+		glog.Error(msg)
+		return nil, errors.New(msg)
+	}
+	fixture := fixtures[index]
+
+	body := fixture.body
+	header := fixture.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if fixture.Gzip {
+		var err error
+		body, err = gzipBytes(body)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func fixtureFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		msg := fmt.Sprintf("Reading fixture directory=%s failed with error=%s",
+			dir, err)
+		glog.Error(msg)
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), kFixtureMetaSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func loadFixture(file string) (*nseFixture, error) {
+	meta, err := ioutil.ReadFile(file)
+	if err != nil {
+		msg := fmt.Sprintf("Reading fixture=%s failed with error=%s", file, err)
+		glog.Error(msg)
+		return nil, err
+	}
+	fixture := &nseFixture{}
+	if err := json.Unmarshal(meta, fixture); err != nil {
+		msg := fmt.Sprintf("Parsing fixture=%s failed with error=%s", file, err)
+		glog.Error(msg)
+		return nil, err
+	}
+	bodyFile := strings.TrimSuffix(file, kFixtureMetaSuffix) + kFixtureBodySuffix
+	fixture.body, err = ioutil.ReadFile(bodyFile)
+	if err != nil {
+		msg := fmt.Sprintf("Reading fixture body=%s failed with error=%s",
+			bodyFile, err)
+		glog.Error(msg)
+		return nil, err
+	}
+	return fixture, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,157 +1,107 @@
 package nse
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"math"
-
-	"github.com/golang/glog"
+	"sort"
+	"time"
 )
 
-func getStrField(
-	records map[string]interface{},
-	field string) (string, error) {
-
-	fieldInt, ok := records[field]
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC records failed. Field %s not found.",
-			field)
-		glog.Error(msg)
-		return "", errors.New(msg)
-	}
-	value, ok := fieldInt.(string)
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC records failed."+
-			"Field %s is not of string type.", field)
-		glog.Error(msg)
-		return "", errors.New(msg)
-	}
-	return value, nil
-}
+// IST is the Indian Standard Time zone in which NSE reports its timestamps.
+var IST = time.FixedZone("IST", 5*60*60+30*60)
 
-func getFloat64Field(
-	records map[string]interface{},
-	field string) (float64, error) {
+const (
+	kOcExpiryDateLayout = "02-Jan-2006"
+	kOcTimestampLayout  = "02-Jan-2006 15:04:05"
 
-	fieldValue, ok := records[field]
-	if !ok {
-		msg := fmt.Sprintf("Parsing records failed. Field %s not found.", field)
-		glog.Error(msg)
-		return 0, errors.New(msg)
-	}
+	kMarketOpenHour    = 9
+	kMarketOpenMinute  = 15
+	kMarketCloseHour   = 15
+	kMarketCloseMinute = 30
+)
 
-	value, ok := fieldValue.(float64)
-	if !ok {
-		msg := fmt.Sprintf("Parsing records failed."+
-			"Field %s is not of float64 type.", field)
-		glog.Error(msg)
-		return 0, errors.New(msg)
+// ParseExpiryDate parses an NSE expiry date like "01-Jun-2023". The returned
+// time is the market close (15:30 IST) of the expiry day, when the contracts
+// stop trading.
+func ParseExpiryDate(expiryDate string) (time.Time, error) {
+	date, err := time.ParseInLocation(kOcExpiryDateLayout, expiryDate, IST)
+	if err != nil {
+		return time.Time{}, err
 	}
-
-	return value, nil
+	return time.Date(date.Year(), date.Month(), date.Day(),
+		kMarketCloseHour, kMarketCloseMinute, 0, 0, IST), nil
 }
 
-func getIntField(
-	records map[string]interface{},
-	field string) (int, error) {
-
-	fieldValue, ok := records[field]
-	if !ok {
-		msg := fmt.Sprintf("Parsing records failed. Field %s not found.", field)
-		glog.Error(msg)
-		return 0, errors.New(msg)
-	}
-
-	value, ok := fieldValue.(int)
-	if !ok {
-		msg := fmt.Sprintf("Parsing field=%s failed. "+
-			"Expected int type found %T type.", field, fieldValue)
-		glog.Error(msg, value)
-		return 0, errors.New(msg)
-	}
-
-	return value, nil
+// ParseOcTimestamp parses an NSE option chain timestamp like
+// "01-Jun-2023 15:30:00" in IST.
+func ParseOcTimestamp(timestamp string) (time.Time, error) {
+	return time.ParseInLocation(kOcTimestampLayout, timestamp, IST)
 }
 
-func getArrayField(
-	records map[string]interface{},
-	field string) ([]interface{}, error) {
-
-	fieldInt, ok := records[field]
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC records failed. Field %s not found.",
-			field)
-		return []interface{}{}, errors.New(msg)
+// roundToStep rounds the number to the nearest multiple of the step, rounding
+// halfway numbers down. It rounds to the nearest integer when the step is not
+// positive.
+func roundToStep(num float64, step float64) float64 {
+	if step <= 0 {
+		return math.Round(num)
 	}
-	value, ok := fieldInt.([]interface{})
-	if !ok {
-		msg := fmt.Sprintf("Parsing OC records failed."+
-			"Field %s is not of array type.", field)
-		return []interface{}{}, errors.New(msg)
-	}
-	return value, nil
+	return roundToPaise(math.Ceil(num/step-0.5) * step)
 }
 
-func getStringToInterfaceMap(
-	records map[string]interface{},
-	field string) (result map[string]interface{}, err error) {
+// roundToPaise rounds a price to 2 decimals, removing the error of the float
+// arithmetic on strikes like 7.5 or 2.35.
+func roundToPaise(price float64) float64 {
+	return math.Round(price*100) / 100
+}
 
-	fieldInt, ok := records[field]
-	if !ok {
-		msg := fmt.Sprintf("Field %s not found in the records.", field)
-		glog.Error(msg)
-		return result, errors.New(msg)
-	}
-	result, ok = fieldInt.(map[string]interface{})
-	if !ok {
-		msg := fmt.Sprintf("Unexpected field %s type", field)
-		glog.Error(msg)
-		return result, errors.New(msg)
+// inferStrikeStep returns the most common gap between consecutive strikes.
+// When gaps are equally common the smaller one wins. Stocks do not have
+// uniform strike steps, so the step is inferred from the listed strikes
+// instead of being fixed per symbol. It returns 0 when there are fewer than
+// two strikes.
+func inferStrikeStep(strikes []float64) float64 {
+	sorted := make([]float64, len(strikes))
+	copy(sorted, strikes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	counts := map[float64]int{}
+	for ii := 1; ii < len(sorted); ii += 1 {
+		gap := roundToPaise(sorted[ii] - sorted[ii-1])
+		if gap <= 0 {
+			continue
+		}
+		counts[gap] += 1
 	}
-	return result, nil
-}
 
-func convertToStringSlice(data []interface{}) []string {
-	result := make([]string, len(data))
-	for i, v := range data {
-		if str, ok := v.(string); ok {
-			result[i] = str
-		} else {
-			// Handle the case where the element is not a string
-			// You can choose to skip, ignore, or perform some other action
-			result[i] = ""
-			glog.Info(fmt.Sprintf("Value %v is not string.", v))
+	step := 0.0
+	for gap, count := range counts {
+		if count > counts[step] || (count == counts[step] && gap < step) {
+			step = gap
 		}
 	}
-	return result
+	return step
 }
 
-func convertToIntSlice(data []interface{}) []int32 {
-	result := make([]int32, len(data))
-	for i, v := range data {
-		if str, ok := v.(int32); ok {
-			result[i] = str
-		} else {
-			// Handle the case where the element is not a string
-			// You can choose to skip, ignore, or perform some other action
-			result[i] = 0
-			glog.Info(fmt.Sprintf("Value %v is not integer.", v))
-		}
+// sleepContext sleeps for the given duration or until the context is done,
+// whichever happens first. It returns the context error in the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return result
 }
 
-func roundToStep(num float64, step int32) int32 {
-	// round the input number to the nearest integer
-	rounded := int32(math.Round(num))
-	// calculate the remainder when divided by 50
-	remainder := rounded % step
-	// calculate the difference from the nearest multiple of 50
-	diff := step - remainder
-	// adjust the rounded number based on the difference
-	if remainder <= (step / 2) {
-		return rounded - remainder
-	} else {
-		return rounded + diff
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
 	}
+	return false
 }